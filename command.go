@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/ppsf"
+)
+
+// command is something vstiPlaginRunner carries out on the plugin thread,
+// sent with session.Do.
+type command interface {
+	command()
+}
+
+// request is a command on its way to the runner. The runner answers every
+// request exactly once on reply, with nil or the error that stopped the
+// command. reply is buffered so the runner never waits for the caller.
+type request struct {
+	cmd   command
+	reply chan error
+}
+
+// loadFXBCommand replaces the plugin state by the bank stored at Path.
+type loadFXBCommand struct {
+	Path string
+}
+
+// saveFXBCommand writes the plugin state to Path.
+type saveFXBCommand struct {
+	Path string
+}
+
+// openGUICommand opens the plugin editor in a window of its own.
+type openGUICommand struct{}
+
+// callCommand runs Fn on the plugin thread. Every plugin call outside the
+// other commands, e.g. Start, Close or a render, goes through it.
+type callCommand struct {
+	Fn func(plugin Plugin) error
+}
+
+func (loadFXBCommand) command() {}
+func (saveFXBCommand) command() {}
+func (openGUICommand) command() {}
+func (callCommand) command()    {}
+
+// loadFXB reads the bank at path and sets it as the plugin state.
+func loadFXB(plugin Plugin, path string) error {
+	fmt.Println("Loading .fxb:", path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read bank file: %w", err)
+	}
+	// 解析できない版のバンクもそのままのバイト列で読み込ませる
+	if bank, err := ppsf.Parse(data); err != nil {
+		fmt.Printf("bank %s is not fully understood, loading it as is: %v\n", path, err)
+	} else if data, err = bank.Marshal(); err != nil {
+		return fmt.Errorf("failed to encode bank: %w", err)
+	}
+	plugin.SetBankData(data)
+
+	fmt.Println("Bank set:", path, "size", len(data))
+	return nil
+}
@@ -0,0 +1,142 @@
+package convert
+
+import (
+	"math"
+	"sort"
+)
+
+// Bend is one point of a pitch bend curve.
+type Bend struct {
+	Time float64 // seconds
+	// Value is the 14-bit pitch bend relative to the centre: -8192..8191,
+	// 0 meaning no bend.
+	Value int16
+}
+
+// anchor is a point of the continuous pitch curve, in fractional MIDI notes.
+type anchor struct {
+	time, pitch float64
+}
+
+// PitchBend returns the pitch bend curve that moves the rounded notes back
+// onto the continuous VOICEVOX pitch. The curve runs through the middle of
+// every voiced vowel and is interpolated linearly in between, without
+// crossing accent phrase boundaries. Only changes are emitted.
+//
+// Points further from the note than BendSensitivity are clamped; use
+// BendRange to pick a sensitivity that holds the whole curve.
+func PitchBend(notes []Note, opt Options) []Bend {
+	var bends []Bend
+	emit := func(t float64, v int16) {
+		if len(bends) > 0 && bends[len(bends)-1].Value == v {
+			return
+		}
+		bends = append(bends, Bend{Time: t, Value: v})
+	}
+	walkCurve(notes, opt, func(t, semitones float64, rest bool) {
+		if rest && len(bends) == 0 {
+			return
+		}
+		emit(t, bendValue(semitones, opt.bendSensitivity()))
+	})
+	if len(bends) > 0 {
+		last := notes[len(notes)-1]
+		emit(last.End(), 0)
+	}
+	return bends
+}
+
+// MaxBendSensitivity is the widest range BendRange picks, the limit of most
+// synthesizers.
+const MaxBendSensitivity = 24.0
+
+// BendRange returns the pitch bend range, in whole semitones, that the
+// curve of PitchBend needs: at least BendSensitivity, at most
+// MaxBendSensitivity. Send it with SensitivityMessages and convert with it
+// set as BendSensitivity.
+func BendRange(notes []Note, opt Options) float64 {
+	need := 0.0
+	walkCurve(notes, opt, func(_, semitones float64, _ bool) {
+		need = math.Max(need, math.Abs(semitones))
+	})
+	// 8191 側で切れないよう 1 ステップ分の余裕を見る
+	need = math.Ceil(need * 8192 / 8191)
+	return math.Min(MaxBendSensitivity, math.Max(opt.bendSensitivity(), need))
+}
+
+// walkCurve calls fn every BendStep with the distance in semitones from the
+// note to the continuous pitch, and once with 0 at the start of every rest.
+func walkCurve(notes []Note, opt Options, fn func(t, semitones float64, rest bool)) {
+	anchors := map[int][]anchor{}
+	for _, n := range notes {
+		if n.Voiced && !n.Rest {
+			anchors[n.Phrase] = append(anchors[n.Phrase], anchor{
+				time:  n.Start + n.Mora.VowelLength/2,
+				pitch: MIDINote(n.Mora.Pitch),
+			})
+		}
+	}
+
+	step := opt.bendStep()
+	for _, n := range notes {
+		if n.Rest {
+			fn(n.Start, 0, true)
+			continue
+		}
+		a := anchors[n.Phrase]
+		for t := n.Start; t < n.End(); t += step {
+			d := 0.0
+			if len(a) > 0 {
+				d = interpolate(a, t) - float64(n.Pitch)
+			}
+			fn(t, d, false)
+		}
+	}
+}
+
+// interpolate returns the pitch of the curve at t, holding the first and
+// last anchor outside their range.
+func interpolate(a []anchor, t float64) float64 {
+	i := sort.Search(len(a), func(i int) bool { return a[i].time >= t })
+	switch {
+	case i == 0:
+		return a[0].pitch
+	case i == len(a):
+		return a[len(a)-1].pitch
+	}
+	p, q := a[i-1], a[i]
+	if q.time == p.time {
+		return q.pitch
+	}
+	return p.pitch + (q.pitch-p.pitch)*(t-p.time)/(q.time-p.time)
+}
+
+// bendValue converts a distance in semitones into a pitch bend value for
+// the given bend range.
+func bendValue(semitones, sensitivity float64) int16 {
+	v := math.Round(semitones / sensitivity * 8192)
+	return int16(math.Max(-8192, math.Min(8191, v)))
+}
+
+// BendMessage encodes a pitch bend as a MIDI channel message, ready for a
+// vst2.MIDIEvent sent with PlugProcessEvents.
+func BendMessage(channel uint8, value int16) [3]byte {
+	v := int(value) + 8192
+	return [3]byte{0xE0 | channel&0x0f, byte(v & 0x7f), byte(v >> 7 & 0x7f)}
+}
+
+// SensitivityMessages sets the pitch bend range of a channel through RPN 0
+// and then deselects the RPN again.
+func SensitivityMessages(channel uint8, semitones float64) [][3]byte {
+	cc := 0xB0 | channel&0x0f
+	whole := math.Floor(semitones)
+	cents := math.Round((semitones - whole) * 100)
+	return [][3]byte{
+		{cc, 101, 0},
+		{cc, 100, 0},
+		{cc, 6, byte(whole)},
+		{cc, 38, byte(cents)},
+		{cc, 101, 127},
+		{cc, 100, 127},
+	}
+}
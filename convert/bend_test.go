@@ -0,0 +1,30 @@
+package convert
+
+import "testing"
+
+func TestBendRangeHoldsQuestionRise(t *testing.T) {
+	q := loadReply(t)
+	notes := FromQuery(q, Options{})
+
+	clamped := func(bends []Bend) int {
+		n := 0
+		for _, b := range bends {
+			if b.Value == 8191 || b.Value == -8192 {
+				n++
+			}
+		}
+		return n
+	}
+	// 既定の ±2 半音では疑問文の上昇を表しきれない
+	if clamped(PitchBend(notes, Options{})) == 0 {
+		t.Fatal("the recorded reply no longer exceeds the default bend range")
+	}
+
+	r := BendRange(notes, Options{})
+	if r <= DefaultBendSensitivity || r > MaxBendSensitivity || r != float64(int(r)) {
+		t.Fatalf("BendRange = %v", r)
+	}
+	if n := clamped(PitchBend(notes, Options{BendSensitivity: r})); n != 0 {
+		t.Errorf("%d bend points clamped at a range of %v semitones", n, r)
+	}
+}
@@ -0,0 +1,283 @@
+// Package convert turns a VOICEVOX audio query into Piapro Studio notes.
+//
+// Every mora becomes one note. VOCALOID style singers start the vowel on the
+// note onset and sing the consonant ahead of it, so a note starts at the
+// vowel of its mora and lasts until the vowel of the next one. Silence
+// before and after the sentence and the pauses of punctuation become rests.
+package convert
+
+import (
+	"math"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/ppsf"
+	"github.com/isanan39s/PiaproStudio_TTS.git/tempo"
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+const (
+	// DefaultTempo is used when Options.Tempo is zero.
+	DefaultTempo = 120.0
+	// DefaultPitch is used when Options.DefaultPitch is zero.
+	DefaultPitch = 60
+	// DefaultBendSensitivity is the General MIDI pitch bend range.
+	DefaultBendSensitivity = 2.0
+	// DefaultBendStep gives one bend point every 10ms.
+	DefaultBendStep = 0.01
+	// DefaultQuestionRise is the 0.3 log Hz rise of the VOICEVOX engine,
+	// about 5.2 semitones.
+	DefaultQuestionRise = 0.3 * 12 / math.Ln2
+	// DefaultQuestionLength is the length of the rising mora in seconds.
+	DefaultQuestionLength = 0.15
+)
+
+// Options controls the conversion.
+type Options struct {
+	// Tempo is the project tempo in BPM used to turn seconds into ticks.
+	Tempo float64
+	// TempoMap replaces Tempo when the project changes tempo. It should be
+	// the map the host transport uses and match the tempo of the bank.
+	TempoMap *tempo.Map
+	// ClipStart is the position in ticks of the clip the notes go into.
+	// FromQuery starts the notes there and ToPPSF positions them relative
+	// to it, as the clip expects.
+	ClipStart uint64
+	// DefaultPitch is the MIDI note given to unvoiced moras when no mora of
+	// the query has a pitch to borrow.
+	DefaultPitch uint8
+	// BendSensitivity is the pitch bend range of the singer in semitones.
+	// 0 means DefaultBendSensitivity.
+	BendSensitivity float64
+	// BendStep is the interval between pitch bend points in seconds.
+	// 0 means DefaultBendStep.
+	BendStep float64
+	// Phonemes maps moras to Piapro phonemes. nil means the built-in table.
+	Phonemes *PhonemeTable
+	// QuestionRise is how far the mora added to the end of an interrogative
+	// phrase rises above the last mora, in semitones. 0 means
+	// DefaultQuestionRise.
+	QuestionRise float64
+	// QuestionLength is the length of that mora in seconds, before
+	// speedScale. 0 means DefaultQuestionLength.
+	QuestionLength float64
+	// NoQuestionRise keeps interrogative phrases flat.
+	NoQuestionRise bool
+}
+
+func (o Options) tempoMap() *tempo.Map {
+	if o.TempoMap != nil {
+		return o.TempoMap
+	}
+	if o.Tempo <= 0 {
+		return tempo.New(DefaultTempo)
+	}
+	return tempo.New(o.Tempo)
+}
+
+func (o Options) bendSensitivity() float64 {
+	if o.BendSensitivity <= 0 {
+		return DefaultBendSensitivity
+	}
+	return o.BendSensitivity
+}
+
+func (o Options) bendStep() float64 {
+	if o.BendStep <= 0 {
+		return DefaultBendStep
+	}
+	return o.BendStep
+}
+
+func (o Options) phonemes() *PhonemeTable {
+	if o.Phonemes == nil {
+		return DefaultPhonemeTable()
+	}
+	return o.Phonemes
+}
+
+func (o Options) questionRise() float64 {
+	if o.QuestionRise <= 0 {
+		return DefaultQuestionRise
+	}
+	return o.QuestionRise
+}
+
+func (o Options) questionLength() float64 {
+	if o.QuestionLength <= 0 {
+		return DefaultQuestionLength
+	}
+	return o.QuestionLength
+}
+
+func (o Options) defaultPitch() uint8 {
+	if o.DefaultPitch == 0 {
+		return DefaultPitch
+	}
+	return o.DefaultPitch
+}
+
+// Ticks converts seconds into ticks at the configured tempo.
+func (o Options) Ticks(sec float64) uint32 {
+	return o.tempoMap().Ticks(sec)
+}
+
+// Note is one mora laid out in seconds.
+type Note struct {
+	Start  float64 // vowel onset
+	Length float64
+	// Pitch is the MIDI note closest to the mora pitch.
+	Pitch   uint8
+	Lyric   string
+	Phoneme string
+	// Velocity follows the volumeScale of the query.
+	Velocity uint8
+	// Voiced is false for devoiced moras. They have no pitch of their own
+	// and borrow the one of the closest voiced mora before (or after) them.
+	Voiced bool
+	// Rest marks silence: the pre/post phoneme time or a pause mora.
+	Rest bool
+	// Phrase is the index of the accent phrase the mora belongs to.
+	Phrase int
+	Mora   voicevox.Mora
+}
+
+// End returns the time right after the note.
+func (n Note) End() float64 { return n.Start + n.Length }
+
+// MIDINote converts a VOICEVOX pitch (natural log of Hz) into a fractional
+// MIDI note number, 69 being A4 (440 Hz).
+func MIDINote(logHz float64) float64 {
+	return 69 + 12*(logHz-math.Log(440))/math.Ln2
+}
+
+// FromQuery lays out the moras of q as notes and rests. The speed, pitch,
+// intonation and volume scales of the query are applied, so the notes
+// follow what the engine itself would synthesize. Interrogative phrases end
+// with an extra rising mora, as the engine adds one when synthesizing.
+func FromQuery(q *voicevox.ResponseData, opt Options) []Note {
+	var notes []Note
+	speed := speedScale(q)
+	vel := velocity(q)
+	t := opt.tempoMap().TickSeconds(uint32(opt.ClipStart))
+	if q.PrePhonemeLength > 0 {
+		notes = append(notes, Note{Start: t, Rest: true})
+		t += q.PrePhonemeLength / speed
+	}
+	for p, moras := range scaledMoras(q, opt) {
+		for _, m := range moras {
+			t += m.ConsonantLength
+			notes = append(notes, Note{
+				Start:    t,
+				Length:   m.VowelLength,
+				Lyric:    hiragana(m.Text),
+				Velocity: vel,
+				Voiced:   m.Voiced(),
+				Phrase:   p,
+				Mora:     m,
+			})
+			t += m.VowelLength
+		}
+		if pm := q.AccentPhrases[p].PauseMora; pm != nil {
+			if pause := q.Pause(*pm) / speed; pause > 0 {
+				notes = append(notes, Note{Start: t, Length: pause, Rest: true, Phrase: p, Mora: *pm})
+				t += pause
+			}
+		}
+	}
+	if q.PostPhonemeLength > 0 {
+		post := q.PostPhonemeLength / speed
+		notes = append(notes, Note{Start: t, Length: post, Rest: true, Phrase: len(q.AccentPhrases) - 1})
+	}
+
+	// 次のモーラの子音ぶんまで伸ばしてレガートにする。休符の後の子音は休符に含める
+	for i := 0; i+1 < len(notes); i++ {
+		notes[i].Length = notes[i+1].Start - notes[i].Start
+	}
+	phonemes := opt.phonemes()
+	for i := range notes {
+		if notes[i].Rest {
+			continue
+		}
+		var next *voicevox.Mora
+		if i+1 < len(notes) {
+			next = &notes[i+1].Mora
+		}
+		notes[i].Phoneme = phonemes.Phoneme(notes[i].Mora, next)
+	}
+	assignPitch(notes, opt.defaultPitch())
+	return notes
+}
+
+// assignPitch rounds the pitch of voiced notes and lets unvoiced notes
+// borrow from their neighbours instead of becoming MIDI note 0.
+func assignPitch(notes []Note, fallback uint8) {
+	last := -1
+	for i := range notes {
+		if notes[i].Rest {
+			continue
+		}
+		if notes[i].Voiced {
+			notes[i].Pitch = clampNote(math.Round(MIDINote(notes[i].Mora.Pitch)))
+			last = i
+		} else if last >= 0 {
+			notes[i].Pitch = notes[last].Pitch
+		}
+	}
+	// 先頭から続く無声モーラは後ろの有声モーラに合わせる
+	next := -1
+	for i := len(notes) - 1; i >= 0; i-- {
+		if notes[i].Voiced {
+			next = i
+			continue
+		}
+		if notes[i].Rest || notes[i].Pitch != 0 {
+			continue
+		}
+		if next >= 0 {
+			notes[i].Pitch = notes[next].Pitch
+		} else {
+			notes[i].Pitch = fallback
+		}
+	}
+}
+
+func clampNote(n float64) uint8 {
+	return uint8(math.Max(0, math.Min(127, n)))
+}
+
+// ToPPSF converts notes into ppsf notes positioned from the start of the
+// clip at opt.ClipStart. Rests are left as gaps.
+func ToPPSF(notes []Note, opt Options) []*ppsf.Note {
+	m := opt.tempoMap()
+	out := make([]*ppsf.Note, 0, len(notes))
+	for _, n := range notes {
+		if n.Rest {
+			continue
+		}
+		// テンポ変化を含めるため、クリップ位置は秒ではなくティックで引く
+		pos := uint32(max(int64(m.Ticks(n.Start))-int64(opt.ClipStart), 0))
+		end := uint32(max(int64(m.Ticks(n.End()))-int64(opt.ClipStart), 0))
+		if end <= pos {
+			end = pos + 1
+		}
+		note := ppsf.NewNote(pos, end-pos, n.Pitch, n.Lyric, n.Phoneme)
+		// 無声化などは歌詞から導けないので固定する
+		note.SetPhonemeLocked(n.Phoneme != "")
+		if n.Velocity != 0 {
+			note.Velocity = n.Velocity
+		}
+		out = append(out, note)
+	}
+	return out
+}
+
+// hiragana converts katakana to hiragana, the form the Piapro Studio editor
+// uses for lyrics. Other characters are kept.
+func hiragana(s string) string {
+	r := []rune(s)
+	for i, c := range r {
+		if c >= 'ァ' && c <= 'ヶ' {
+			r[i] = c - 'ァ' + 'ぁ'
+		}
+	}
+	return string(r)
+}
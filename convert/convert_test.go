@@ -0,0 +1,123 @@
+package convert
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/tempo"
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+// loadReply reads testdata/audio_query.json, the /audio_query answer for
+// "テキスト？" of vvengin_reply.txt without its notes.
+func loadReply(t *testing.T) *voicevox.ResponseData {
+	t.Helper()
+	data, err := os.ReadFile("../testdata/audio_query.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var q voicevox.ResponseData
+	if err := json.Unmarshal(data, &q); err != nil {
+		t.Fatal(err)
+	}
+	return &q
+}
+
+func TestToPPSFClipStart(t *testing.T) {
+	const clip = 240 // my_presetb.fxb のクリップ位置
+	m := tempo.New(120)
+	m.SetTempo(0.125, 90) // クリップより前のテンポ変化
+	opt := Options{TempoMap: m, ClipStart: clip}
+	notes := FromQuery(loadReply(t), opt)
+	if got, want := notes[0].Start, m.TickSeconds(clip); got != want {
+		t.Errorf("first note starts at %v s, want the clip at %v s", got, want)
+	}
+
+	out := ToPPSF(notes, opt)
+	i := 0
+	for _, n := range notes {
+		if n.Rest {
+			continue
+		}
+		if got, want := out[i].Pos+clip, m.Ticks(n.Start); got != want {
+			t.Errorf("note %d at tick %d of the project, want %d", i, got, want)
+		}
+		i++
+	}
+	if i != len(out) {
+		t.Errorf("got %d notes, want %d", len(out), i)
+	}
+}
+
+// commaQuery returns the recorded reply read twice with a comma between,
+// テキスト？、テキスト？, the pause mora lasting mora seconds.
+func commaQuery(t *testing.T, mora float64) *voicevox.ResponseData {
+	t.Helper()
+	q := loadReply(t)
+	first := q.AccentPhrases[0]
+	first.PauseMora = &voicevox.Mora{Text: "、", Vowel: "pau", VowelLength: mora}
+	q.AccentPhrases = []voicevox.AccentPhrase{first, q.AccentPhrases[0]}
+	return q
+}
+
+func TestRests(t *testing.T) {
+	pauseLength := 0.5
+	tests := []struct {
+		name        string
+		pauseLength *float64
+		pauseScale  *float64
+		speed       float64
+		pause       float64 // 読点の無音 (秒)
+	}{
+		{"mora length", nil, nil, 1, 0.3},
+		{"pauseLength", &pauseLength, nil, 1, 0.5},
+		{"pauseLengthScale", nil, scale(2), 1, 0.6},
+		{"both", &pauseLength, scale(0.5), 1, 0.25},
+		{"speedScale", nil, nil, 2, 0.15},
+	}
+	for _, tt := range tests {
+		q := commaQuery(t, 0.3)
+		q.PauseLength, q.PauseLengthScale, q.SpeedScale = tt.pauseLength, tt.pauseScale, tt.speed
+		notes := FromQuery(q, Options{})
+
+		var rests []Note
+		for _, n := range notes {
+			if n.Rest {
+				rests = append(rests, n)
+			}
+		}
+		if len(rests) != 3 {
+			t.Fatalf("%s: got %d rests, want pre, comma and post", tt.name, len(rests))
+		}
+		pre, comma, post := rests[0], rests[1], rests[2]
+
+		// 休符は次のモーラの子音まで含む
+		first := q.AccentPhrases[0].Moras[0].ConsonantLength / tt.speed
+		if pre != notes[0] || pre.Start != 0 || !near(pre.Length, q.PrePhonemeLength/tt.speed+first) {
+			t.Errorf("%s: pre rest %v+%v, want 0+%v", tt.name, pre.Start, pre.Length, q.PrePhonemeLength/tt.speed+first)
+		}
+		// 読点の休符は 1 つ目の句の最後 (疑問の上昇を含む) の直後に入る
+		phrase := q.PrePhonemeLength + DefaultQuestionLength
+		for _, m := range q.AccentPhrases[0].Moras {
+			phrase += m.ConsonantLength + m.VowelLength
+		}
+		phrase /= tt.speed
+		if !near(comma.Start, phrase) || !near(comma.Length, tt.pause+first) {
+			t.Errorf("%s: comma rest %v+%v, want %v+%v", tt.name, comma.Start, comma.Length, phrase, tt.pause+first)
+		}
+		if post != notes[len(notes)-1] || !near(post.Length, q.PostPhonemeLength/tt.speed) {
+			t.Errorf("%s: post rest lasts %v, want %v", tt.name, post.Length, q.PostPhonemeLength/tt.speed)
+		}
+	}
+}
+
+func TestNoRests(t *testing.T) {
+	q := commaQuery(t, 0)
+	q.PrePhonemeLength, q.PostPhonemeLength = 0, 0
+	for _, n := range FromQuery(q, Options{}) {
+		if n.Rest {
+			t.Errorf("rest at %v without any silence", n.Start)
+		}
+	}
+}
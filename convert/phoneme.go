@@ -0,0 +1,110 @@
+package convert
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+//go:embed phonemes.json
+var defaultPhonemes []byte
+
+// PhonemeTable maps VOICEVOX phonemes to the X-SAMPA style symbols Piapro
+// Studio uses for Japanese. The default table is phonemes.json; entries can
+// be corrected at run time with LoadPhonemeTable.
+type PhonemeTable struct {
+	// Vowels maps VOICEVOX vowels, including devoiced ones ("I", "U"), the
+	// moraic nasal "N" and the geminate "cl".
+	Vowels map[string]string `json:"vowels"`
+	// Consonants maps VOICEVOX consonants, palatalized ones ("ky", "sh")
+	// included.
+	Consonants map[string]string `json:"consonants"`
+	// BeforeI overrides Consonants in front of "i", where Japanese
+	// consonants palatalize (き "k' i", に "J i").
+	BeforeI map[string]string `json:"before_i"`
+	// Nasal gives the moraic nasal ん by the consonant of the next mora.
+	// The "" entry is used before vowels, pauses and at the end.
+	Nasal map[string]string `json:"nasal"`
+}
+
+// DefaultPhonemeTable returns a fresh copy of the built-in table.
+func DefaultPhonemeTable() *PhonemeTable {
+	t := &PhonemeTable{}
+	if err := json.Unmarshal(defaultPhonemes, t); err != nil {
+		panic("convert: bad built-in phonemes.json: " + err.Error())
+	}
+	return t
+}
+
+// LoadPhonemeTable reads a JSON table laid out like phonemes.json. Its
+// entries are merged over the built-in table, so a file only needs the
+// entries that should change.
+func LoadPhonemeTable(path string) (*PhonemeTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var override PhonemeTable
+	if err := json.Unmarshal(data, &override); err != nil {
+		return nil, fmt.Errorf("phoneme table %s: %w", path, err)
+	}
+	t := DefaultPhonemeTable()
+	merge(t.Vowels, override.Vowels)
+	merge(t.Consonants, override.Consonants)
+	merge(t.BeforeI, override.BeforeI)
+	merge(t.Nasal, override.Nasal)
+	return t, nil
+}
+
+func merge(dst, src map[string]string) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// Phoneme returns the Piapro phoneme string of m, e.g. "k' i" for キ or
+// "i0" for a devoiced イ. next is the following mora, used to pick the
+// variant of ん, which palatalizes like the consonant after it (んに "J");
+// pass nil at the end of a sentence. Symbols missing from the table are
+// passed through unchanged.
+func (t *PhonemeTable) Phoneme(m voicevox.Mora, next *voicevox.Mora) string {
+	if m.Vowel == "N" {
+		key := ""
+		if next != nil {
+			key = next.Consonant
+			// イ段の前では口蓋化した子音 (に → ny) で引く
+			if _, ok := t.BeforeI[key]; ok && strings.EqualFold(next.Vowel, "i") {
+				if p, ok := t.Nasal[key+"y"]; ok {
+					return p
+				}
+			}
+		}
+		if p, ok := t.Nasal[key]; ok {
+			return p
+		}
+		return lookup(t.Vowels, m.Vowel)
+	}
+
+	vowel := lookup(t.Vowels, m.Vowel)
+	if m.Consonant == "" {
+		return vowel
+	}
+	consonant := lookup(t.Consonants, m.Consonant)
+	if strings.EqualFold(m.Vowel, "i") {
+		if p, ok := t.BeforeI[m.Consonant]; ok {
+			consonant = p
+		}
+	}
+	return consonant + " " + vowel
+}
+
+func lookup(table map[string]string, key string) string {
+	if p, ok := table[key]; ok {
+		return p
+	}
+	return key
+}
@@ -0,0 +1,90 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/ppsf"
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+// my_presetb.fxb was typed into Piapro Studio as こんにちは, so its notes
+// carry the phonemes the editor derives.
+func TestPhonemeMatchesEditor(t *testing.T) {
+	bank, err := ppsf.ReadFile("../my_presetb.fxb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := bank.VocalTracks()[0].Notes()[:5]
+	moras := []voicevox.Mora{
+		{Text: "コ", Consonant: "k", Vowel: "o"},
+		{Text: "ン", Vowel: "N"},
+		{Text: "ニ", Consonant: "n", Vowel: "i"},
+		{Text: "チ", Consonant: "ch", Vowel: "i"},
+		{Text: "ワ", Consonant: "w", Vowel: "a"},
+	}
+	table := DefaultPhonemeTable()
+	for i, m := range moras {
+		var next *voicevox.Mora
+		if i+1 < len(moras) {
+			next = &moras[i+1]
+		}
+		if got, want := table.Phoneme(m, next), notes[i].Phoneme; got != want {
+			t.Errorf("%s (%s): got %q, want %q", m.Text, notes[i].Lyric, got, want)
+		}
+	}
+}
+
+func TestNasalBeforeI(t *testing.T) {
+	table := DefaultPhonemeTable()
+	n := voicevox.Mora{Text: "ン", Vowel: "N"}
+	for _, c := range []struct {
+		next voicevox.Mora
+		want string
+	}{
+		{voicevox.Mora{Consonant: "n", Vowel: "i"}, "J"},
+		{voicevox.Mora{Consonant: "m", Vowel: "i"}, "m'"},
+		{voicevox.Mora{Consonant: "k", Vowel: "i"}, "N'"},
+		{voicevox.Mora{Consonant: "k", Vowel: "I"}, "N'"},
+		{voicevox.Mora{Consonant: "k", Vowel: "a"}, "N"},
+		{voicevox.Mora{Consonant: "t", Vowel: "i"}, "n"},
+		{voicevox.Mora{Vowel: "i"}, "N\\"},
+	} {
+		if got := table.Phoneme(n, &c.next); got != c.want {
+			t.Errorf("ん before %s%s: got %q, want %q", c.next.Consonant, c.next.Vowel, got, c.want)
+		}
+	}
+}
+
+func TestLoadPhonemeTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "phonemes.json")
+	if err := os.WriteFile(path, []byte(`{"vowels":{"o":"O"},"before_i":{"k":"k"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	table, err := LoadPhonemeTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := DefaultPhonemeTable()
+	tests := []struct {
+		mora voicevox.Mora
+		want string
+	}{
+		{voicevox.Mora{Text: "ト", Consonant: "t", Vowel: "o"}, "t O"}, // 差し替えた項目
+		{voicevox.Mora{Text: "キ", Consonant: "k", Vowel: "i"}, "k i"},
+		{voicevox.Mora{Text: "テ", Consonant: "t", Vowel: "e"}, def.Phoneme(voicevox.Mora{Consonant: "t", Vowel: "e"}, nil)}, // 残りは既定のまま
+	}
+	for _, tt := range tests {
+		if got := table.Phoneme(tt.mora, nil); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.mora.Text, got, tt.want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(`{"vowels":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPhonemeTable(path); err == nil {
+		t.Error("LoadPhonemeTable accepted a broken file")
+	}
+}
@@ -0,0 +1,31 @@
+package convert
+
+import (
+	"math"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+// maxQuestionPitch caps the added mora like the engine does, in log Hz.
+const maxQuestionPitch = 6.5
+
+// questionMora returns the mora the engine appends to an interrogative
+// phrase: the last vowel held for a short while at a higher pitch. ok is
+// false when the phrase does not rise, e.g. because it ends devoiced.
+func questionMora(phrase voicevox.AccentPhrase, opt Options) (m voicevox.Mora, ok bool) {
+	if !phrase.IsInterrogative || opt.NoQuestionRise || len(phrase.Moras) == 0 {
+		return m, false
+	}
+	last := phrase.Moras[len(phrase.Moras)-1]
+	if !last.Voiced() {
+		return m, false
+	}
+	// 音高は log(Hz) なので半音を自然対数に直して足す
+	pitch := last.Pitch + opt.questionRise()/12*math.Ln2
+	return voicevox.Mora{
+		Text:        "ー",
+		Vowel:       last.Vowel,
+		VowelLength: opt.questionLength(),
+		Pitch:       math.Min(pitch, maxQuestionPitch),
+	}, true
+}
@@ -0,0 +1,83 @@
+package convert
+
+import (
+	"math"
+	"testing"
+)
+
+// rise returns the note FromQuery appends to the interrogative phrase of
+// the recorded reply, and the note before it.
+func rise(t *testing.T, notes []Note) (last, added *Note) {
+	t.Helper()
+	var voiced []*Note
+	for i := range notes {
+		if !notes[i].Rest {
+			voiced = append(voiced, &notes[i])
+		}
+	}
+	if len(voiced) < 2 || voiced[len(voiced)-1].Lyric != "ー" {
+		return nil, nil
+	}
+	return voiced[len(voiced)-2], voiced[len(voiced)-1]
+}
+
+func TestQuestionRise(t *testing.T) {
+	q := loadReply(t) // テキスト？
+	last, added := rise(t, FromQuery(q, Options{}))
+	if added == nil {
+		t.Fatal("the interrogative phrase has no rising mora")
+	}
+	if last.Lyric != "と" {
+		t.Errorf("rising mora follows %q, want と", last.Lyric)
+	}
+	semitones := func() float64 { return MIDINote(added.Mora.Pitch) - MIDINote(last.Mora.Pitch) }
+	if got := semitones(); math.Abs(got-DefaultQuestionRise) > 1e-9 {
+		t.Errorf("rise = %v semitones, want %v", got, DefaultQuestionRise)
+	}
+	if !near(added.Mora.VowelLength, DefaultQuestionLength) {
+		t.Errorf("rising mora lasts %v s, want %v", added.Mora.VowelLength, DefaultQuestionLength)
+	}
+	if added.Phoneme == "" || added.Phoneme != last.Phoneme[len(last.Phoneme)-1:] {
+		t.Errorf("rising mora sings %q after %q, want the vowel held", added.Phoneme, last.Phoneme)
+	}
+
+	_, added = rise(t, FromQuery(q, Options{QuestionRise: 5, QuestionLength: 0.3}))
+	if added == nil {
+		t.Fatal("the interrogative phrase has no rising mora")
+	}
+	if got := semitones(); math.Abs(got-5) > 1e-9 || !near(added.Mora.VowelLength, 0.3) {
+		t.Errorf("rising mora = %v semitones for %v s, want 5 for 0.3", got, added.Mora.VowelLength)
+	}
+}
+
+func TestNoQuestionRise(t *testing.T) {
+	if _, added := rise(t, FromQuery(loadReply(t), Options{NoQuestionRise: true})); added != nil {
+		t.Error("NoQuestionRise still added a rising mora")
+	}
+
+	// 無声化して終わる句は上げない
+	q := loadReply(t)
+	moras := q.AccentPhrases[0].Moras
+	moras[len(moras)-1].Pitch = 0
+	moras[len(moras)-1].Vowel = "O"
+	if _, added := rise(t, FromQuery(q, Options{})); added != nil {
+		t.Error("a devoiced ending got a rising mora")
+	}
+
+	// 疑問文でない句も上げない
+	q = loadReply(t)
+	q.AccentPhrases[0].IsInterrogative = false
+	if _, added := rise(t, FromQuery(q, Options{})); added != nil {
+		t.Error("a statement got a rising mora")
+	}
+}
+
+func TestQuestionPitchCap(t *testing.T) {
+	_, added := rise(t, FromQuery(loadReply(t), Options{QuestionRise: 60}))
+	if added == nil {
+		t.Fatal("the interrogative phrase has no rising mora")
+	}
+	if want := clampNote(math.Round(MIDINote(maxQuestionPitch))); added.Pitch != want {
+		t.Errorf("rising mora at note %d, want it capped at %d", added.Pitch, want)
+	}
+}
@@ -0,0 +1,82 @@
+package convert
+
+import (
+	"math"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/ppsf"
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+// speedScale returns the speedScale of q. Zero, as in a query that does
+// not set it, means normal speed.
+func speedScale(q *voicevox.ResponseData) float64 {
+	if q.SpeedScale <= 0 {
+		return 1
+	}
+	return q.SpeedScale
+}
+
+// intonationScale returns the intonationScale of q. A query that does not
+// set it keeps its intonation; 0 is valid and flattens it.
+func intonationScale(q *voicevox.ResponseData) float64 {
+	if q.IntonationScale == nil {
+		return 1
+	}
+	return *q.IntonationScale
+}
+
+// scaledMoras returns copies of the moras of every accent phrase with the
+// query's scales applied the way the VOICEVOX engine does before
+// synthesis: lengths are divided by speedScale, pitch (log Hz) is
+// multiplied by 2^pitchScale, and the voiced moras are spread around their
+// mean by intonationScale. The rising mora of interrogative phrases is
+// added first, so it is scaled like the others.
+func scaledMoras(q *voicevox.ResponseData, opt Options) [][]voicevox.Mora {
+	speed := speedScale(q)
+	shift := math.Pow(2, q.PitchScale)
+	phrases := make([][]voicevox.Mora, len(q.AccentPhrases))
+	sum, voiced := 0.0, 0
+	for i, phrase := range q.AccentPhrases {
+		moras := append([]voicevox.Mora(nil), phrase.Moras...)
+		if m, ok := questionMora(phrase, opt); ok {
+			moras = append(moras, m)
+		}
+		for j, m := range moras {
+			m.ConsonantLength /= speed
+			m.VowelLength /= speed
+			m.Pitch *= shift
+			if m.Voiced() {
+				sum += m.Pitch
+				voiced++
+			}
+			moras[j] = m
+		}
+		phrases[i] = moras
+	}
+
+	if voiced == 0 {
+		return phrases
+	}
+	// エンジンと同じく文全体の有声モーラの平均を中心に抑揚を伸縮する
+	intonation := intonationScale(q)
+	mean := sum / float64(voiced)
+	for _, moras := range phrases {
+		for j := range moras {
+			if moras[j].Voiced() {
+				moras[j].Pitch = (moras[j].Pitch-mean)*intonation + mean
+			}
+		}
+	}
+	return phrases
+}
+
+// velocity maps volumeScale onto note velocity, 1.0 being the editor
+// default. A query that does not set it means 1.0. Mute, 0, gives the
+// lowest velocity, 1, since a note velocity of 0 means the default.
+func velocity(q *voicevox.ResponseData) uint8 {
+	v := 1.0
+	if q.VolumeScale != nil {
+		v = max(*q.VolumeScale, 0)
+	}
+	return uint8(math.Max(1, math.Min(127, math.Round(ppsf.DefaultVelocity*v))))
+}
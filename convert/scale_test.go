@@ -0,0 +1,109 @@
+package convert
+
+import (
+	"math"
+	"testing"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/ppsf"
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+func scale(v float64) *float64 { return &v }
+
+// flatten returns the moras of scaledMoras phrase by phrase in one slice.
+func flatten(q *voicevox.ResponseData) []voicevox.Mora {
+	var out []voicevox.Mora
+	for _, moras := range scaledMoras(q, Options{}) {
+		out = append(out, moras...)
+	}
+	return out
+}
+
+func TestSpeedScale(t *testing.T) {
+	base := flatten(loadReply(t))
+	q := loadReply(t)
+	q.SpeedScale = 2
+	for i, m := range flatten(q) {
+		if !near(m.ConsonantLength, base[i].ConsonantLength/2) || !near(m.VowelLength, base[i].VowelLength/2) {
+			t.Errorf("mora %d %s: lengths %v/%v at speed 2, want half of %v/%v",
+				i, m.Text, m.ConsonantLength, m.VowelLength, base[i].ConsonantLength, base[i].VowelLength)
+		}
+	}
+}
+
+func TestPitchScale(t *testing.T) {
+	base := flatten(loadReply(t))
+	q := loadReply(t)
+	q.PitchScale = 0.5
+	for i, m := range flatten(q) {
+		if want := base[i].Pitch * math.Sqrt2; !near(m.Pitch, want) {
+			t.Errorf("mora %d %s: pitch %v, want %v", i, m.Text, m.Pitch, want)
+		}
+	}
+}
+
+func TestIntonationScale(t *testing.T) {
+	base := flatten(loadReply(t))
+	mean, n := 0.0, 0
+	for _, m := range base {
+		if m.Voiced() {
+			mean += m.Pitch
+			n++
+		}
+	}
+	mean /= float64(n)
+
+	tests := []struct {
+		scale *float64
+		k     float64 // 平均からの距離の倍率
+	}{
+		{nil, 1}, // 省略されたら抑揚はそのまま
+		{scale(1), 1},
+		{scale(0), 0},
+		{scale(2), 2},
+	}
+	for _, tt := range tests {
+		q := loadReply(t)
+		q.IntonationScale = tt.scale
+		for i, m := range flatten(q) {
+			want := base[i].Pitch
+			if m.Voiced() {
+				want = (want-mean)*tt.k + mean
+			}
+			if !near(m.Pitch, want) {
+				t.Errorf("intonation %v: mora %d %s pitch %v, want %v", tt.k, i, m.Text, m.Pitch, want)
+			}
+		}
+	}
+}
+
+func TestVolumeScale(t *testing.T) {
+	tests := []struct {
+		scale *float64
+		want  uint8
+	}{
+		{nil, ppsf.DefaultVelocity},
+		{scale(1), ppsf.DefaultVelocity},
+		{scale(0.5), uint8(math.Round(ppsf.DefaultVelocity * 0.5))},
+		{scale(0), 1}, // 無音は最小のベロシティ
+		{scale(100), 127},
+	}
+	for _, tt := range tests {
+		q := loadReply(t)
+		q.VolumeScale = tt.scale
+		for _, n := range FromQuery(q, Options{}) {
+			if !n.Rest && n.Velocity != tt.want {
+				t.Errorf("volume %v: %s velocity %d, want %d", deref(tt.scale), n.Lyric, n.Velocity, tt.want)
+			}
+		}
+	}
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func deref(p *float64) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+const dictUsage = `usage: dict [--voicevox URL] <command>
+  list
+  add <surface> <pronunciation> <accent> [word_type] [priority]
+  update <uuid> <surface> <pronunciation> <accent> [word_type] [priority]
+  delete <uuid>
+  export <file>
+  import [--override] <file>
+  sync <file>`
+
+// runDict is the "dict" command managing the engine's user dictionary.
+func runDict(args []string) error {
+	url := ""
+	if len(args) >= 2 && args[0] == "--voicevox" {
+		url = args[1]
+		args = args[2:]
+	}
+	if len(args) == 0 {
+		return errors.New(dictUsage)
+	}
+	client := voicevox.NewClient(url)
+	ctx := context.Background()
+
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "list" && len(args) == 0:
+		dict, err := client.UserDict(ctx)
+		if err != nil {
+			return err
+		}
+		ids := make([]string, 0, len(dict))
+		for id := range dict {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return dict[ids[i]].Surface < dict[ids[j]].Surface })
+		for _, id := range ids {
+			w := dict[id]
+			fmt.Printf("%s  %s  %s  accent=%d priority=%d\n", id, w.Surface, w.Pronunciation, w.AccentType, w.Priority)
+		}
+		return nil
+
+	case cmd == "add" && len(args) >= 3 && len(args) <= 5:
+		w, err := parseWord(args)
+		if err != nil {
+			return err
+		}
+		id, err := client.AddWord(ctx, w)
+		if err != nil {
+			return err
+		}
+		fmt.Println(id)
+		return nil
+
+	case cmd == "update" && len(args) >= 4 && len(args) <= 6:
+		w, err := parseWord(args[1:])
+		if err != nil {
+			return err
+		}
+		return client.UpdateWord(ctx, args[0], w)
+
+	case cmd == "delete" && len(args) == 1:
+		return client.DeleteWord(ctx, args[0])
+
+	case cmd == "export" && len(args) == 1:
+		dict, err := client.UserDict(ctx)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(dict, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(args[0], data, 0644)
+
+	case cmd == "import" && (len(args) == 1 || len(args) == 2 && args[0] == "--override"):
+		data, err := os.ReadFile(args[len(args)-1])
+		if err != nil {
+			return err
+		}
+		var dict map[string]voicevox.UserDictWord
+		if err := json.Unmarshal(data, &dict); err != nil {
+			return fmt.Errorf("%s: %w", args[len(args)-1], err)
+		}
+		return client.ImportUserDict(ctx, dict, len(args) == 2)
+
+	case cmd == "sync" && len(args) == 1:
+		return syncDict(client, args[0])
+	}
+	return errors.New(dictUsage)
+}
+
+// parseWord parses "<surface> <pronunciation> <accent> [word_type] [priority]".
+func parseWord(args []string) (voicevox.Word, error) {
+	w := voicevox.Word{Surface: args[0], Pronunciation: args[1]}
+	var err error
+	if w.AccentType, err = strconv.Atoi(args[2]); err != nil {
+		return w, fmt.Errorf("invalid accent: %w", err)
+	}
+	if len(args) > 3 {
+		w.WordType = args[3]
+	}
+	if len(args) > 4 {
+		p, err := strconv.Atoi(args[4])
+		if err != nil {
+			return w, fmt.Errorf("invalid priority: %w", err)
+		}
+		w.Priority = &p
+	}
+	return w, nil
+}
+
+// syncDict makes the engine read the words of the dictionary file at path
+// the way the file says.
+func syncDict(client *voicevox.Client, path string) error {
+	words, err := voicevox.LoadWords(path)
+	if err != nil {
+		return err
+	}
+	added, updated, err := client.SyncWords(context.Background(), words)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("user dictionary %s: %d added, %d updated\n", path, added, updated)
+	return nil
+}
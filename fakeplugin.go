@@ -0,0 +1,189 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"unsafe"
+
+	"pipelined.dev/audio/vst2"
+	"pipelined.dev/signal"
+)
+
+// fakePluginPath selects fakePlugin instead of a plugin DLL.
+const fakePluginPath = "fake"
+
+// fakeMIDI is a MIDI event received by fakePlugin. Frame counts from the
+// first processed sample.
+type fakeMIDI struct {
+	Frame int64
+	Data  [3]byte
+}
+
+// fakePlugin is an in-memory plugin standing in for Piapro Studio. It plays
+// a sine at the pitch of every held note, records the MIDI it receives and
+// keeps bank chunks as given, which is enough to drive the host, render and
+// bank code on any OS.
+type fakePlugin struct {
+	// Channels is the number of output channels ProcessFloat fills.
+	Channels int
+	// OnDispatch, when set, answers the opcodes fakePlugin does not handle
+	// itself.
+	OnDispatch func(opcode vst2.PluginOpcode, index int32, value int64, ptr unsafe.Pointer, opt float32) uintptr
+
+	mu         sync.Mutex
+	sampleRate float64
+	bufferSize int
+	running    bool
+	closed     bool
+	bank       []byte
+	params     []float32
+	frame      int64
+	midi       []fakeMIDI
+	pending    []fakeMIDI
+	notes      map[uint8]*fakeVoice
+	bend       float64 // semitones
+}
+
+type fakeVoice struct {
+	gain  float64
+	phase float64
+}
+
+// fakeBendRange is the pitch bend range of fakePlugin in semitones.
+const fakeBendRange = 2
+
+func newFakePlugin() *fakePlugin {
+	return &fakePlugin{
+		Channels:   2,
+		sampleRate: 48000,
+		params:     make([]float32, 4),
+		notes:      map[uint8]*fakeVoice{},
+	}
+}
+
+func (p *fakePlugin) Dispatch(opcode vst2.PluginOpcode, index int32, value int64, ptr unsafe.Pointer, opt float32) uintptr {
+	if opcode == vst2.PlugProcessEvents && ptr != nil {
+		events := (*vst2.EventsPtr)(ptr)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for i := 0; i < events.NumEvents(); i++ {
+			if e, ok := events.Event(i).(*vst2.MIDIEvent); ok {
+				// 次の ProcessFloat のブロック内で DeltaFrames の位置から効かせる
+				p.pending = append(p.pending, fakeMIDI{Frame: p.frame + int64(e.DeltaFrames), Data: e.Data})
+			}
+		}
+		return 1
+	}
+	if p.OnDispatch != nil {
+		return p.OnDispatch(opcode, index, value, ptr, opt)
+	}
+	return 0
+}
+
+func (p *fakePlugin) ProcessFloat(in, out vst2.FloatBuffer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	left := out.Channel(0)
+	for i := range left {
+		for len(p.pending) > 0 && p.pending[0].Frame <= p.frame {
+			p.midiEvent(p.pending[0])
+			p.pending = p.pending[1:]
+		}
+		left[i] = float32(p.sample())
+		p.frame++
+	}
+	for c := 1; c < p.Channels; c++ {
+		copy(out.Channel(c), left)
+	}
+}
+
+func (p *fakePlugin) midiEvent(e fakeMIDI) {
+	p.midi = append(p.midi, e)
+	status, d1, d2 := e.Data[0]&0xf0, e.Data[1], e.Data[2]
+	switch {
+	case status == 0x90 && d2 > 0:
+		p.notes[d1] = &fakeVoice{gain: 0.2 * float64(d2) / 127}
+	case status == 0x80 || status == 0x90:
+		delete(p.notes, d1)
+	case status == 0xb0 && (d1 == 120 || d1 == 123): // all sound / notes off
+		clear(p.notes)
+	case status == 0xe0:
+		v := int(d1) | int(d2)<<7 - 8192
+		p.bend = float64(v) / 8192 * fakeBendRange
+	}
+}
+
+func (p *fakePlugin) sample() float64 {
+	s := 0.0
+	for note, v := range p.notes {
+		hz := 440 * math.Pow(2, (float64(note)+p.bend-69)/12)
+		s += v.gain * math.Sin(2*math.Pi*v.phase)
+		v.phase = math.Mod(v.phase+hz/p.sampleRate, 1)
+	}
+	return s
+}
+
+// MIDI returns the MIDI events played so far, in order.
+func (p *fakePlugin) MIDI() []fakeMIDI {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]fakeMIDI(nil), p.midi...)
+}
+
+func (p *fakePlugin) GetBankData() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]byte(nil), p.bank...)
+}
+
+func (p *fakePlugin) SetBankData(data []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bank = append([]byte(nil), data...)
+}
+
+func (p *fakePlugin) NumParams() int { return len(p.params) }
+
+func (p *fakePlugin) ParamName(index int) string { return "param" + string(rune('0'+index)) }
+
+func (p *fakePlugin) ParamValue(index int) float32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.params[index]
+}
+
+func (p *fakePlugin) SetParamValue(index int, value float32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.params[index] = value
+}
+
+func (p *fakePlugin) SetSampleRate(sampleRate signal.Frequency) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sampleRate = float64(sampleRate)
+}
+
+func (p *fakePlugin) SetBufferSize(bufferSize int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bufferSize = bufferSize
+}
+
+func (p *fakePlugin) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running = true
+}
+
+func (p *fakePlugin) Suspend() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running = false
+}
+
+func (p *fakePlugin) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+}
@@ -2,7 +2,10 @@ module github.com/isanan39s/PiaproStudio_TTS.git
 
 go 1.25.5
 
-require pipelined.dev/audio/vst2 v0.11.0
+require (
+	pipelined.dev/audio/vst2 v0.11.0
+	pipelined.dev/signal v0.10.0
+)
 
 require (
 	github.com/go-audio/audio v1.0.0 // indirect
@@ -10,5 +13,4 @@ require (
 	github.com/go-audio/wav v1.1.0 // indirect
 	golang.org/x/text v0.32.0 // indirect
 	pipelined.dev/pipe v0.11.0 // indirect
-	pipelined.dev/signal v0.10.0 // indirect
 )
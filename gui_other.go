@@ -0,0 +1,12 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// OpenPluginGUIWithWindow needs a Win32 window to host the editor.
+func OpenPluginGUIWithWindow(plugin Plugin, opcodes map[string]int) error {
+	return errors.New("the plugin GUI is only available on Windows")
+}
+
+func pumpWindowMessages() bool { return false }
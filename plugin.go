@@ -0,0 +1,29 @@
+package main
+
+import (
+	"unsafe"
+
+	"pipelined.dev/audio/vst2"
+	"pipelined.dev/signal"
+)
+
+// Plugin is the part of *vst2.Plugin the host uses. Besides the real
+// Piapro Studio DLL it is implemented by fakePlugin, so the host can run
+// without Windows.
+type Plugin interface {
+	Dispatch(opcode vst2.PluginOpcode, index int32, value int64, ptr unsafe.Pointer, opt float32) uintptr
+	ProcessFloat(in, out vst2.FloatBuffer)
+	GetBankData() []byte
+	SetBankData(data []byte)
+	NumParams() int
+	ParamName(index int) string
+	ParamValue(index int) float32
+	SetParamValue(index int, value float32)
+	SetSampleRate(sampleRate signal.Frequency)
+	SetBufferSize(bufferSize int)
+	Start()
+	Suspend()
+	Close()
+}
+
+var _ Plugin = (*vst2.Plugin)(nil)
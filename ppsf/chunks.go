@@ -0,0 +1,298 @@
+package ppsf
+
+import "fmt"
+
+// Info is the INFO chunk. Every bank seen so far stores a single zero byte.
+type Info struct {
+	Data []byte
+}
+
+func (*Info) Tag() Tag { return TagInfo }
+
+func decodeInfo(data []byte) (Chunk, error) { return &Info{Data: data}, nil }
+
+func (c *Info) encode(w *writer) { w.bytes(c.Data) }
+
+// Project is the PROJ chunk: global project settings and the voicebank the
+// project was created with.
+type Project struct {
+	// Header holds the fixed fields in front of the voicebank ids. They are
+	// not decoded yet.
+	Header [31]byte
+	// VoiceID is the voicebank of the project, e.g. "MIKU_V4X_Original_EVEC".
+	VoiceID string
+	// DefaultVoiceID is the voicebank new tracks are created with.
+	DefaultVoiceID string
+	// Body holds the licence codes, output bus ids and track order. It is
+	// kept verbatim.
+	Body []byte
+}
+
+func (*Project) Tag() Tag { return TagProject }
+
+func decodeProject(data []byte) (Chunk, error) {
+	r := newReader(data)
+	p := &Project{}
+	copy(p.Header[:], r.bytes(len(p.Header)))
+	p.VoiceID = r.str8()
+	p.DefaultVoiceID = r.str8()
+	p.Body = r.rest()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return p, nil
+}
+
+func (p *Project) encode(w *writer) {
+	w.bytes(p.Header[:])
+	w.str8(p.VoiceID)
+	w.str8(p.DefaultVoiceID)
+	w.bytes(p.Body)
+}
+
+// Transport is the TRNS chunk. Both fixtures store six zero bytes.
+type Transport struct {
+	Data []byte
+}
+
+func (*Transport) Tag() Tag { return TagTransport }
+
+func decodeTransport(data []byte) (Chunk, error) { return &Transport{Data: data}, nil }
+
+func (c *Transport) encode(w *writer) { w.bytes(c.Data) }
+
+// Config is the CONF chunk. Both fixtures store ten zero bytes.
+type Config struct {
+	Data []byte
+}
+
+func (*Config) Tag() Tag { return TagConfig }
+
+func decodeConfig(data []byte) (Chunk, error) { return &Config{Data: data}, nil }
+
+func (c *Config) encode(w *writer) { w.bytes(c.Data) }
+
+// Devices is the DVCS chunk holding the audio/MIDI device setup.
+type Devices struct {
+	Data []byte
+}
+
+func (*Devices) Tag() Tag { return TagDevices }
+
+func decodeDevices(data []byte) (Chunk, error) { return &Devices{Data: data}, nil }
+
+func (c *Devices) encode(w *writer) { w.bytes(c.Data) }
+
+// Tracks is the TRKS chunk. It holds one TrackList per track kind (AETS,
+// METS, VETS, V3TS, MIDS, MODS, AIDS, AODS, ...) followed by a short tail.
+type Tracks struct {
+	Lists []Chunk
+	Tail  []byte
+}
+
+func (*Tracks) Tag() Tag { return TagTracks }
+
+func decodeTracks(data []byte) (Chunk, error) {
+	r := newReader(data)
+	t := &Tracks{}
+	// 末尾に 1 バイトだけチャンクではないデータが付いている
+	for r.len() >= 8 {
+		c, err := readChunk(r, trackLevel)
+		if err != nil {
+			return nil, err
+		}
+		t.Lists = append(t.Lists, c)
+	}
+	t.Tail = r.rest()
+	return t, nil
+}
+
+func (t *Tracks) encode(w *writer) {
+	for _, c := range t.Lists {
+		w.chunk(c)
+	}
+	w.bytes(t.Tail)
+}
+
+// List returns the track list with the given tag, or nil.
+func (t *Tracks) List(tag Tag) *TrackList {
+	for _, c := range t.Lists {
+		if l, ok := c.(*TrackList); ok && l.ID == tag {
+			return l
+		}
+	}
+	return nil
+}
+
+// VocalTracks returns the V3TK tracks of the V3TS list.
+func (t *Tracks) VocalTracks() []*VocalTrack {
+	l := t.List(TagVocalTracks)
+	if l == nil {
+		return nil
+	}
+	var tracks []*VocalTrack
+	for _, c := range l.Tracks {
+		if v, ok := c.(*VocalTrack); ok {
+			tracks = append(tracks, v)
+		}
+	}
+	return tracks
+}
+
+// TrackList is a counted list of tracks of one kind, e.g. V3TS holding V3TK
+// entries or AODS holding AODT entries.
+type TrackList struct {
+	ID     Tag
+	Tracks []Chunk
+}
+
+func (l *TrackList) Tag() Tag { return l.ID }
+
+func (l *TrackList) encode(w *writer) {
+	w.count(len(l.Tracks), "tracks")
+	for _, c := range l.Tracks {
+		w.chunk(c)
+	}
+}
+
+var trackItems = decoders{
+	TagVocalTrack: decodeVocalTrack,
+	TagAudioOut:   decodeAudioOutTrack,
+}
+
+func decodeTrackList(tag Tag) func(data []byte) (Chunk, error) {
+	return func(data []byte) (Chunk, error) {
+		r := newReader(data)
+		n := int(r.u8())
+		l := &TrackList{ID: tag}
+		for i := 0; i < n; i++ {
+			c, err := readChunk(r, trackItems)
+			if err != nil {
+				return nil, err
+			}
+			l.Tracks = append(l.Tracks, c)
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		if r.len() != 0 {
+			return nil, fmt.Errorf("%d bytes after %d tracks", r.len(), n)
+		}
+		return l, nil
+	}
+}
+
+// TrackHeader is the layout shared by V3TK, AODT and the other track records.
+type TrackHeader struct {
+	ID    uint16 // object id, referenced from PROJ and AODT.Sources
+	Flags uint16
+	Mode  uint8
+	// Inserts and Strip are ids of plugin records in the PLGS chunk. Strip is
+	// the mixer, send and EQ every track carries.
+	Inserts []uint16
+	Strip   []uint16
+	Output  uint16 // 0xffff when unassigned
+	Param   uint16 // parameter record id, 0xffff when unused
+	Routing [7]byte
+	// Sources are the ids of the tracks routed into this one.
+	Sources []uint16
+}
+
+func (h *TrackHeader) decode(r *reader) {
+	h.ID = r.u16()
+	h.Flags = r.u16()
+	h.Mode = r.u8()
+	h.Inserts = r.ids()
+	h.Strip = r.ids()
+	h.Output = r.u16()
+	h.Param = r.u16()
+	copy(h.Routing[:], r.bytes(len(h.Routing)))
+	h.Sources = r.ids()
+}
+
+func (h *TrackHeader) encode(w *writer) {
+	w.u16(h.ID)
+	w.u16(h.Flags)
+	w.u8(h.Mode)
+	w.ids(h.Inserts)
+	w.ids(h.Strip)
+	w.u16(h.Output)
+	w.u16(h.Param)
+	w.bytes(h.Routing[:])
+	w.ids(h.Sources)
+}
+
+// VocalTrack is a V3TK entry: one Piapro Studio vocal track.
+type VocalTrack struct {
+	TrackHeader
+	Reserved [3]byte
+	// VoiceID is the singer, e.g. "MIKU_V4X_Original_EVEC".
+	VoiceID string
+	// Index is the position of the track in the V3TS list.
+	Index uint8
+	Tail  [2]byte
+}
+
+func (*VocalTrack) Tag() Tag { return TagVocalTrack }
+
+func decodeVocalTrack(data []byte) (Chunk, error) {
+	r := newReader(data)
+	t := &VocalTrack{}
+	t.TrackHeader.decode(r)
+	copy(t.Reserved[:], r.bytes(len(t.Reserved)))
+	t.VoiceID = r.str8()
+	t.Tail[0] = r.u8()
+	t.Index = r.u8()
+	t.Tail[1] = r.u8()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.len() != 0 {
+		return nil, fmt.Errorf("%d unexpected bytes at end of track", r.len())
+	}
+	return t, nil
+}
+
+func (t *VocalTrack) encode(w *writer) {
+	t.TrackHeader.encode(w)
+	w.bytes(t.Reserved[:])
+	w.str8(t.VoiceID)
+	w.u8(t.Tail[0])
+	w.u8(t.Index)
+	w.u8(t.Tail[1])
+}
+
+// AudioOutTrack is an AODT entry: one of the host output buses.
+type AudioOutTrack struct {
+	TrackHeader
+	Reserved [9]byte
+	// Name is the host port, "VDAW Host Output" for the VST build.
+	Name string
+	// Channel is the first host output channel of the stereo pair.
+	Channel uint16
+}
+
+func (*AudioOutTrack) Tag() Tag { return TagAudioOut }
+
+func decodeAudioOutTrack(data []byte) (Chunk, error) {
+	r := newReader(data)
+	t := &AudioOutTrack{}
+	t.TrackHeader.decode(r)
+	copy(t.Reserved[:], r.bytes(len(t.Reserved)))
+	t.Name = r.str8()
+	t.Channel = r.u16()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.len() != 0 {
+		return nil, fmt.Errorf("%d unexpected bytes at end of track", r.len())
+	}
+	return t, nil
+}
+
+func (t *AudioOutTrack) encode(w *writer) {
+	t.TrackHeader.encode(w)
+	w.bytes(t.Reserved[:])
+	w.str8(t.Name)
+	w.u16(t.Channel)
+}
@@ -0,0 +1,116 @@
+package ppsf
+
+import "fmt"
+
+// Clips is the CLPS chunk. It is a series of untagged, counted lists of
+// clips: vocal clips (V3CL) in one list, automation clips (AMCL) in another.
+// The remaining lists are empty in every bank seen so far.
+type Clips struct {
+	Groups [][]Chunk
+}
+
+func (*Clips) Tag() Tag { return TagClips }
+
+var clipItems = decoders{
+	TagVocalClip: decodeVocalClip,
+}
+
+func decodeClips(data []byte) (Chunk, error) {
+	r := newReader(data)
+	c := &Clips{}
+	for r.len() > 0 {
+		n := int(r.u8())
+		group := []Chunk{}
+		for i := 0; i < n; i++ {
+			clip, err := readChunk(r, clipItems)
+			if err != nil {
+				return nil, err
+			}
+			group = append(group, clip)
+		}
+		c.Groups = append(c.Groups, group)
+	}
+	return c, nil
+}
+
+func (c *Clips) encode(w *writer) {
+	for _, group := range c.Groups {
+		w.count(len(group), "clips")
+		for _, clip := range group {
+			w.chunk(clip)
+		}
+	}
+}
+
+// VocalClips returns every V3CL clip.
+func (c *Clips) VocalClips() []*VocalClip {
+	var clips []*VocalClip
+	for _, group := range c.Groups {
+		for _, chunk := range group {
+			if v, ok := chunk.(*VocalClip); ok {
+				clips = append(clips, v)
+			}
+		}
+	}
+	return clips
+}
+
+// VocalClip is a V3CL entry: a region of a vocal track holding notes.
+type VocalClip struct {
+	// Track is the index of the owning track (VocalTrack.Index).
+	Track uint16
+	Flags uint16
+	Mode  uint8
+	// Events are indices into the EVTS chunk.
+	Events  []uint32
+	VoiceID string
+	Kind    uint16
+	// Start and Length are in ticks. Note positions are relative to Start.
+	Start        uint64
+	Length       uint64
+	SourceLength uint32
+	Tail         [12]byte
+}
+
+func (*VocalClip) Tag() Tag { return TagVocalClip }
+
+func decodeVocalClip(data []byte) (Chunk, error) {
+	r := newReader(data)
+	c := &VocalClip{}
+	c.Track = r.u16()
+	c.Flags = r.u16()
+	c.Mode = r.u8()
+	n := int(r.u8())
+	for i := 0; i < n && r.err == nil; i++ {
+		c.Events = append(c.Events, r.u32())
+	}
+	c.VoiceID = r.str8()
+	c.Kind = r.u16()
+	c.Start = r.u64()
+	c.Length = r.u64()
+	c.SourceLength = r.u32()
+	copy(c.Tail[:], r.bytes(len(c.Tail)))
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.len() != 0 {
+		return nil, fmt.Errorf("%d unexpected bytes at end of clip", r.len())
+	}
+	return c, nil
+}
+
+func (c *VocalClip) encode(w *writer) {
+	w.u16(c.Track)
+	w.u16(c.Flags)
+	w.u8(c.Mode)
+	w.count(len(c.Events), "events")
+	for _, i := range c.Events {
+		w.u32(i)
+	}
+	w.str8(c.VoiceID)
+	w.u16(c.Kind)
+	w.u64(c.Start)
+	w.u64(c.Length)
+	w.u32(c.SourceLength)
+	w.bytes(c.Tail[:])
+}
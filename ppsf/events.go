@@ -0,0 +1,229 @@
+package ppsf
+
+import "fmt"
+
+// EventNote is the Event.Kind of a note.
+const EventNote = 0x08
+
+// TicksPerQuarter is the resolution of note positions and lengths.
+const TicksPerQuarter = 480
+
+// Events is the EVTS chunk. Vocal clips refer to its entries by index.
+type Events struct {
+	Events []*Event
+	Tail   []byte
+}
+
+func (*Events) Tag() Tag { return TagEvents }
+
+// Event is one entry of the EVTS chunk. Notes are decoded; other kinds keep
+// their payload in Data.
+type Event struct {
+	Kind uint8
+	Note *Note
+	Data []byte
+}
+
+// Note is a single sung note. Pos is in ticks from the start of the clip that
+// owns the note.
+type Note struct {
+	Pos      uint32
+	Pitch    uint8 // MIDI note number, 60 = C4
+	Length   uint32
+	Velocity uint8
+	// BendDepth, BendLength, Portamento, Decay, Accent and Opening are the
+	// VOCALOID note parameters, in the order Piapro Studio stores them.
+	BendDepth  uint8
+	BendLength uint8
+	Portamento uint8
+	Decay      uint8
+	Accent     uint8
+	Opening    uint8
+	Lyric      string
+	// PhonemeLock is non-zero when the phoneme was typed by hand instead of
+	// being derived from the lyric. The editor writes 1; other values are
+	// kept as read. See PhonemeLocked.
+	PhonemeLock uint8
+	Phoneme     string
+	Reserved    [2]byte
+	Style       string // "normal"
+	Vibrato     Vibrato
+}
+
+// Vibrato is the vibrato attached to a note. A zero Vibrato means none.
+type Vibrato struct {
+	Type   uint16
+	Length uint16 // ticks
+	Depth  []VibratoPoint
+	Rate   []VibratoPoint
+	Tail   uint16
+}
+
+// VibratoPoint is one point of a vibrato depth or rate curve.
+type VibratoPoint struct {
+	Value uint16
+	Pos   uint32
+}
+
+// Default note parameters, as set by the Piapro Studio editor.
+const (
+	DefaultVelocity  = 64
+	DefaultBendDepth = 8
+	DefaultDecay     = 50
+	DefaultAccent    = 50
+	DefaultOpening   = 127
+	DefaultStyle     = "normal"
+)
+
+// NewNote returns a note with the editor's default parameters and no vibrato.
+func NewNote(pos, length uint32, pitch uint8, lyric, phoneme string) *Note {
+	return &Note{
+		Pos:       pos,
+		Pitch:     pitch,
+		Length:    length,
+		Velocity:  DefaultVelocity,
+		BendDepth: DefaultBendDepth,
+		Decay:     DefaultDecay,
+		Accent:    DefaultAccent,
+		Opening:   DefaultOpening,
+		Lyric:     lyric,
+		Phoneme:   phoneme,
+		Style:     DefaultStyle,
+	}
+}
+
+// PhonemeLocked reports whether the phoneme was typed by hand.
+func (n *Note) PhonemeLocked() bool { return n.PhonemeLock != 0 }
+
+// SetPhonemeLocked locks the phoneme, or lets the editor derive it from
+// the lyric again.
+func (n *Note) SetPhonemeLocked(locked bool) {
+	if locked {
+		n.PhonemeLock = 1
+	} else {
+		n.PhonemeLock = 0
+	}
+}
+
+// End returns the tick right after the note.
+func (n *Note) End() uint32 { return n.Pos + n.Length }
+
+func decodeEvents(data []byte) (Chunk, error) {
+	r := newReader(data)
+	n := int(r.u8())
+	e := &Events{}
+	for i := 0; i < n && r.err == nil; i++ {
+		ev := &Event{Kind: r.u8()}
+		payload := r.bytes(int(r.u16()))
+		if r.err != nil {
+			break
+		}
+		if ev.Kind != EventNote {
+			ev.Data = payload
+		} else {
+			note, err := decodeNote(payload)
+			if err != nil {
+				return nil, fmt.Errorf("event %d: %w", i, err)
+			}
+			ev.Note = note
+		}
+		e.Events = append(e.Events, ev)
+	}
+	e.Tail = r.rest()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return e, nil
+}
+
+func decodeNote(data []byte) (*Note, error) {
+	r := newReader(data)
+	n := &Note{}
+	n.Pos = r.u32()
+	n.Pitch = r.u8()
+	n.Length = r.u32()
+	n.Velocity = r.u8()
+	n.BendDepth = r.u8()
+	n.BendLength = r.u8()
+	n.Portamento = r.u8()
+	n.Decay = r.u8()
+	n.Accent = r.u8()
+	n.Opening = r.u8()
+	n.Lyric = r.str8()
+	n.PhonemeLock = r.u8()
+	n.Phoneme = r.str8()
+	copy(n.Reserved[:], r.bytes(len(n.Reserved)))
+	n.Style = r.str8()
+	n.Vibrato.Type = r.u16()
+	n.Vibrato.Length = r.u16()
+	n.Vibrato.Depth = r.vibratoPoints()
+	n.Vibrato.Rate = r.vibratoPoints()
+	n.Vibrato.Tail = r.u16()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.len() != 0 {
+		return nil, fmt.Errorf("%d unexpected bytes at end of note", r.len())
+	}
+	return n, nil
+}
+
+func (r *reader) vibratoPoints() []VibratoPoint {
+	n := int(r.u8())
+	var pts []VibratoPoint
+	for i := 0; i < n && r.err == nil; i++ {
+		pts = append(pts, VibratoPoint{Value: r.u16(), Pos: r.u32()})
+	}
+	return pts
+}
+
+func (e *Events) encode(w *writer) {
+	w.count(len(e.Events), "events")
+	for _, ev := range e.Events {
+		w.u8(ev.Kind)
+		at := len(w.buf)
+		w.u16(0)
+		if ev.Note != nil {
+			ev.Note.encode(w)
+		} else {
+			w.bytes(ev.Data)
+		}
+		size := len(w.buf) - at - 2
+		if size > 0xffff {
+			w.fail(fmt.Errorf("event of %d bytes is too large", size))
+		}
+		w.buf[at], w.buf[at+1] = byte(size), byte(size>>8)
+	}
+	w.bytes(e.Tail)
+}
+
+func (n *Note) encode(w *writer) {
+	w.u32(n.Pos)
+	w.u8(n.Pitch)
+	w.u32(n.Length)
+	w.u8(n.Velocity)
+	w.u8(n.BendDepth)
+	w.u8(n.BendLength)
+	w.u8(n.Portamento)
+	w.u8(n.Decay)
+	w.u8(n.Accent)
+	w.u8(n.Opening)
+	w.str8(n.Lyric)
+	w.u8(n.PhonemeLock)
+	w.str8(n.Phoneme)
+	w.bytes(n.Reserved[:])
+	w.str8(n.Style)
+	w.u16(n.Vibrato.Type)
+	w.u16(n.Vibrato.Length)
+	w.vibratoPoints(n.Vibrato.Depth)
+	w.vibratoPoints(n.Vibrato.Rate)
+	w.u16(n.Vibrato.Tail)
+}
+
+func (w *writer) vibratoPoints(pts []VibratoPoint) {
+	w.count(len(pts), "vibrato points")
+	for _, p := range pts {
+		w.u16(p.Value)
+		w.u32(p.Pos)
+	}
+}
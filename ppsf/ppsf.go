@@ -0,0 +1,193 @@
+// Package ppsf reads and writes the "PPSF" bank container that Piapro Studio returns
+// from Plugin.GetBankData (the bytes SaveFXB writes to .fxb files).
+//
+// A bank is the magic "PPSF", a uint32 length of the rest of the data, a
+// uint16-prefixed version string ("2.0.0") and a sequence of tagged chunks.
+// Every chunk is a four-letter tag followed by a uint32 little-endian length
+// and its body. Chunks whose layout is known are decoded into typed structs;
+// everything else is kept as Raw so that no byte of the bank is lost.
+package ppsf
+
+import (
+	"fmt"
+	"os"
+)
+
+// Magic is the signature at the start of every bank.
+const Magic = "PPSF"
+
+// Tag is a four-letter chunk identifier such as "PROJ".
+type Tag [4]byte
+
+func (t Tag) String() string { return string(t[:]) }
+
+// Chunk tags used by Piapro Studio 4.x banks.
+var (
+	TagInfo         = Tag{'I', 'N', 'F', 'O'}
+	TagProject      = Tag{'P', 'R', 'O', 'J'}
+	TagTransport    = Tag{'T', 'R', 'N', 'S'}
+	TagConfig       = Tag{'C', 'O', 'N', 'F'}
+	TagDevices      = Tag{'D', 'V', 'C', 'S'}
+	TagTracks       = Tag{'T', 'R', 'K', 'S'}
+	TagAudioEffects = Tag{'A', 'E', 'T', 'S'}
+	TagMIDIEffects  = Tag{'M', 'E', 'T', 'S'}
+	TagVSTiTracks   = Tag{'V', 'E', 'T', 'S'}
+	TagVocalTracks  = Tag{'V', '3', 'T', 'S'}
+	TagVocalTrack   = Tag{'V', '3', 'T', 'K'}
+	TagMIDIIn       = Tag{'M', 'I', 'D', 'S'}
+	TagMIDIOut      = Tag{'M', 'O', 'D', 'S'}
+	TagAudioIn      = Tag{'A', 'I', 'D', 'S'}
+	TagAudioOuts    = Tag{'A', 'O', 'D', 'S'}
+	TagAudioOut     = Tag{'A', 'O', 'D', 'T'}
+	TagClips        = Tag{'C', 'L', 'P', 'S'}
+	TagVocalClip    = Tag{'V', '3', 'C', 'L'}
+	TagEvents       = Tag{'E', 'V', 'T', 'S'}
+)
+
+// Chunk is one tagged block of a bank.
+type Chunk interface {
+	Tag() Tag
+	// encode appends the chunk body, without tag and length, to w.
+	encode(w *writer)
+}
+
+// Raw is a chunk whose body is not decoded. It is kept verbatim.
+type Raw struct {
+	ID   Tag
+	Data []byte
+}
+
+func (c *Raw) Tag() Tag { return c.ID }
+
+func (c *Raw) encode(w *writer) { w.bytes(c.Data) }
+
+// Bank is a parsed PPSF bank.
+type Bank struct {
+	Version string
+	Chunks  []Chunk
+}
+
+// Parse decodes a bank as returned by Plugin.GetBankData.
+func Parse(data []byte) (*Bank, error) {
+	r := newReader(data)
+	if magic := r.tag(); r.err == nil && magic.String() != Magic {
+		return nil, fmt.Errorf("ppsf: bad magic %q", magic.String())
+	}
+	size := r.u32()
+	if r.err == nil && int(size) != r.len() {
+		return nil, fmt.Errorf("ppsf: header says %d bytes but %d follow", size, r.len())
+	}
+	b := &Bank{Version: r.str16()}
+	if r.err != nil {
+		return nil, fmt.Errorf("ppsf: header: %w", r.err)
+	}
+
+	chunks, err := readChunks(r, topLevel)
+	if err != nil {
+		return nil, fmt.Errorf("ppsf: %w", err)
+	}
+	b.Chunks = chunks
+	return b, nil
+}
+
+// ReadFile parses the bank stored at path, e.g. a file written by SaveFXB.
+func ReadFile(path string) (*Bank, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Chunk returns the first top-level chunk with the given tag, or nil.
+func (b *Bank) Chunk(tag Tag) Chunk {
+	for _, c := range b.Chunks {
+		if c.Tag() == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+// Project returns the PROJ chunk, or nil if the bank has none.
+func (b *Bank) Project() *Project {
+	p, _ := b.Chunk(TagProject).(*Project)
+	return p
+}
+
+// Tracks returns the TRKS chunk, or nil if the bank has none.
+func (b *Bank) Tracks() *Tracks {
+	t, _ := b.Chunk(TagTracks).(*Tracks)
+	return t
+}
+
+// Clips returns the CLPS chunk, or nil if the bank has none.
+func (b *Bank) Clips() *Clips {
+	c, _ := b.Chunk(TagClips).(*Clips)
+	return c
+}
+
+// Events returns the EVTS chunk, or nil if the bank has none.
+func (b *Bank) Events() *Events {
+	e, _ := b.Chunk(TagEvents).(*Events)
+	return e
+}
+
+// decoders maps the tags we understand to their body decoders. The map is
+// chosen per nesting level since the same tag may mean different things in
+// different containers.
+type decoders map[Tag]func(data []byte) (Chunk, error)
+
+var topLevel, trackLevel decoders
+
+func init() {
+	topLevel = decoders{
+		TagInfo:      decodeInfo,
+		TagProject:   decodeProject,
+		TagTransport: decodeTransport,
+		TagConfig:    decodeConfig,
+		TagDevices:   decodeDevices,
+		TagTracks:    decodeTracks,
+		TagClips:     decodeClips,
+		TagEvents:    decodeEvents,
+	}
+	trackLevel = decoders{}
+	for _, tag := range []Tag{
+		TagAudioEffects, TagMIDIEffects, TagVSTiTracks, TagVocalTracks,
+		TagMIDIIn, TagMIDIOut, TagAudioIn, TagAudioOuts,
+	} {
+		trackLevel[tag] = decodeTrackList(tag)
+	}
+}
+
+// readChunks reads tagged chunks until r is exhausted.
+func readChunks(r *reader, known decoders) ([]Chunk, error) {
+	var chunks []Chunk
+	for r.len() > 0 {
+		c, err := readChunk(r, known)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, nil
+}
+
+// readChunk reads one tagged chunk and decodes its body if the tag is known.
+func readChunk(r *reader, known decoders) (Chunk, error) {
+	tag := r.tag()
+	size := r.u32()
+	data := r.bytes(int(size))
+	if r.err != nil {
+		return nil, fmt.Errorf("chunk %q: %w", tag.String(), r.err)
+	}
+	decode, ok := known[tag]
+	if !ok {
+		return &Raw{ID: tag, Data: data}, nil
+	}
+	c, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %q: %w", tag.String(), err)
+	}
+	return c, nil
+}
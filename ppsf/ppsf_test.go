@@ -0,0 +1,60 @@
+package ppsf
+
+import "testing"
+
+// The fixtures are banks saved by Piapro Studio 4.x: my_preset.fxb has one
+// vocal track, my_presetb.fxb two.
+var fixtures = []struct {
+	path   string
+	tracks int
+}{
+	{"../my_preset.fxb", 1},
+	{"../my_presetb.fxb", 2},
+}
+
+func TestParseFixtures(t *testing.T) {
+	wantTags := []Tag{
+		TagInfo, TagProject, TagTransport, TagConfig, TagDevices,
+		TagTracks, TagClips, TagEvents,
+		{'P', 'L', 'G', 'S'}, {'E', 'D', 'T', 'S'},
+	}
+	for _, f := range fixtures {
+		t.Run(f.path, func(t *testing.T) {
+			b, err := ReadFile(f.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(b.Chunks) != len(wantTags) {
+				t.Fatalf("got %d chunks, want %d", len(b.Chunks), len(wantTags))
+			}
+			for i, c := range b.Chunks {
+				if c.Tag() != wantTags[i] {
+					t.Errorf("chunk %d is %s, want %s", i, c.Tag(), wantTags[i])
+				}
+			}
+			// PLGS と EDTS は未解析のまま保持する
+			for _, c := range b.Chunks[8:] {
+				if _, ok := c.(*Raw); !ok {
+					t.Errorf("%s decoded as %T, want *Raw", c.Tag(), c)
+				}
+			}
+
+			p := b.Project()
+			if p == nil {
+				t.Fatal("no PROJ chunk")
+			}
+			if p.VoiceID != "MIKU_V4X_Original_EVEC" {
+				t.Errorf("VoiceID = %q", p.VoiceID)
+			}
+			tracks := b.Tracks().VocalTracks()
+			if len(tracks) != f.tracks {
+				t.Fatalf("got %d V3TK tracks, want %d", len(tracks), f.tracks)
+			}
+			for _, tr := range tracks {
+				if tr.VoiceID != "MIKU_V4X_Original_EVEC" {
+					t.Errorf("track %d VoiceID = %q", tr.Index, tr.VoiceID)
+				}
+			}
+		})
+	}
+}
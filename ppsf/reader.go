@@ -0,0 +1,99 @@
+package ppsf
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errShort = errors.New("unexpected end of data")
+
+// reader walks a little-endian byte slice. The first error sticks, so
+// callers can read a whole record and check r.err once at the end.
+type reader struct {
+	buf []byte
+	off int
+	err error
+}
+
+func newReader(buf []byte) *reader { return &reader{buf: buf} }
+
+// len returns the number of unread bytes.
+func (r *reader) len() int { return len(r.buf) - r.off }
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > r.len() {
+		r.err = errShort
+		return nil
+	}
+	b := r.buf[r.off : r.off+n : r.off+n]
+	r.off += n
+	return b
+}
+
+// rest returns everything that has not been read yet.
+func (r *reader) rest() []byte { return r.bytes(r.len()) }
+
+func (r *reader) u8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) u16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (r *reader) u32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *reader) u64() uint64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (r *reader) tag() Tag {
+	var t Tag
+	copy(t[:], r.bytes(4))
+	return t
+}
+
+// str8 reads a string with a one-byte length prefix, the form used for
+// lyrics, phonemes and voicebank ids.
+func (r *reader) str8() string {
+	return string(r.bytes(int(r.u8())))
+}
+
+// str16 reads a string with a uint16 length prefix (the bank version).
+func (r *reader) str16() string {
+	return string(r.bytes(int(r.u16())))
+}
+
+// ids reads a one-byte count followed by that many uint16 object ids.
+func (r *reader) ids() []uint16 {
+	n := int(r.u8())
+	if n == 0 {
+		return nil
+	}
+	ids := make([]uint16, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		ids = append(ids, r.u16())
+	}
+	return ids
+}
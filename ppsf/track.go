@@ -0,0 +1,159 @@
+package ppsf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Track is a vocal track together with the clips and notes that belong to
+// it. It is a view into the bank: changes are written back by Marshal.
+type Track struct {
+	*VocalTrack
+	bank *Bank
+}
+
+// VocalTracks returns the vocal tracks of the bank in V3TS order.
+func (b *Bank) VocalTracks() []*Track {
+	tracks := b.Tracks()
+	if tracks == nil {
+		return nil
+	}
+	var out []*Track
+	for _, v := range tracks.VocalTracks() {
+		out = append(out, &Track{VocalTrack: v, bank: b})
+	}
+	return out
+}
+
+// Singer returns the voicebank id of the track, e.g. "MIKU_V4X_Original_EVEC".
+func (t *Track) Singer() string { return t.VoiceID }
+
+// SetSinger assigns a voicebank to the track and to all of its clips.
+func (t *Track) SetSinger(voiceID string) {
+	t.VoiceID = voiceID
+	for _, c := range t.Clips() {
+		c.VoiceID = voiceID
+	}
+}
+
+// Clips returns the vocal clips placed on the track.
+func (t *Track) Clips() []*VocalClip {
+	clips := t.bank.Clips()
+	if clips == nil {
+		return nil
+	}
+	var out []*VocalClip
+	for _, c := range clips.VocalClips() {
+		if c.Track == uint16(t.Index) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Notes returns the notes of every clip on the track, clip by clip. Note
+// positions are relative to the start of their clip.
+func (t *Track) Notes() []*Note {
+	events := t.bank.Events()
+	if events == nil {
+		return nil
+	}
+	var notes []*Note
+	for _, c := range t.Clips() {
+		for _, i := range c.Events {
+			if int(i) < len(events.Events) && events.Events[i].Note != nil {
+				notes = append(notes, events.Events[i].Note)
+			}
+		}
+	}
+	return notes
+}
+
+// ClearNotes removes the notes of every clip on the track from the bank and
+// shrinks the clips to nothing, e.g. to fill a template with new notes.
+// Events that are not notes are kept.
+func (t *Track) ClearNotes() {
+	events := t.bank.Events()
+	if events == nil {
+		return
+	}
+	drop := map[uint32]bool{}
+	for _, c := range t.Clips() {
+		kept := c.Events[:0]
+		for _, i := range c.Events {
+			if int(i) < len(events.Events) && events.Events[i].Note != nil {
+				drop[i] = true
+			} else {
+				kept = append(kept, i)
+			}
+		}
+		c.Events = kept
+		c.Length = 0
+		c.SourceLength = 0
+	}
+
+	// 残ったイベントを詰めて、全クリップの参照を付け替える
+	index := make([]uint32, len(events.Events))
+	var list []*Event
+	for i, e := range events.Events {
+		index[i] = uint32(len(list))
+		if !drop[uint32(i)] {
+			list = append(list, e)
+		}
+	}
+	events.Events = list
+	if clips := t.bank.Clips(); clips != nil {
+		for _, c := range clips.VocalClips() {
+			for j, i := range c.Events {
+				if int(i) < len(index) {
+					c.Events[j] = index[i]
+				}
+			}
+		}
+	}
+}
+
+// End returns the tick right after the last note of the vocal clips, counted
+// from the start of the project. It is 0 when the bank has no notes.
+func (b *Bank) End() uint64 {
+	clips, events := b.Clips(), b.Events()
+	if clips == nil || events == nil {
+		return 0
+	}
+	var end uint64
+	for _, c := range clips.VocalClips() {
+		for _, i := range c.Events {
+			if int(i) < len(events.Events) && events.Events[i].Note != nil {
+				end = max(end, c.Start+uint64(events.Events[i].Note.End()))
+			}
+		}
+	}
+	return end
+}
+
+// AddNote appends n to the first clip of the track and lengthens the clip
+// if the note ends after it.
+func (t *Track) AddNote(n *Note) error {
+	events := t.bank.Events()
+	if events == nil {
+		return errors.New("ppsf: bank has no EVTS chunk")
+	}
+	clips := t.Clips()
+	if len(clips) == 0 {
+		return fmt.Errorf("ppsf: track %d has no clip", t.Index)
+	}
+	c := clips[0]
+	if len(c.Events) >= 0xff || len(events.Events) >= 0xff {
+		return fmt.Errorf("ppsf: clip of track %d is full", t.Index)
+	}
+
+	c.Events = append(c.Events, uint32(len(events.Events)))
+	events.Events = append(events.Events, &Event{Kind: EventNote, Note: n})
+	if end := uint64(n.End()); end > c.Length {
+		c.Length = end
+	}
+	if n.End() > c.SourceLength {
+		c.SourceLength = n.End()
+	}
+	return nil
+}
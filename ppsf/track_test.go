@@ -0,0 +1,42 @@
+package ppsf
+
+import "testing"
+
+func TestClearNotes(t *testing.T) {
+	b, err := ReadFile("../my_presetb.fxb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracks := b.VocalTracks()
+	other := tracks[1].Notes()
+	tracks[0].ClearNotes()
+	if err := tracks[0].AddNote(NewNote(0, 480, 60, "ら", "4 a")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := b.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err = Parse(data); err != nil {
+		t.Fatal(err)
+	}
+	tracks = b.VocalTracks()
+	notes := tracks[0].Notes()
+	if len(notes) != 1 || notes[0].Lyric != "ら" {
+		t.Fatalf("track 0 holds %d notes after ClearNotes and AddNote", len(notes))
+	}
+	if c := tracks[0].Clips()[0]; c.Length != 480 {
+		t.Errorf("clip length %d, want 480", c.Length)
+	}
+	// 他のトラックのノートは付け替えた参照で残る
+	got := tracks[1].Notes()
+	if len(got) != len(other) {
+		t.Fatalf("track 1 holds %d notes, want %d", len(got), len(other))
+	}
+	for i := range got {
+		if got[i].Lyric != other[i].Lyric || got[i].Pos != other[i].Pos {
+			t.Errorf("track 1 note %d = %s@%d, want %s@%d", i, got[i].Lyric, got[i].Pos, other[i].Lyric, other[i].Pos)
+		}
+	}
+}
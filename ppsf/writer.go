@@ -0,0 +1,97 @@
+package ppsf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// writer is the counterpart of reader. Like reader, the first error sticks.
+type writer struct {
+	buf []byte
+	err error
+}
+
+func (w *writer) bytes(b []byte) { w.buf = append(w.buf, b...) }
+
+func (w *writer) u8(v uint8) { w.buf = append(w.buf, v) }
+
+func (w *writer) u16(v uint16) { w.buf = binary.LittleEndian.AppendUint16(w.buf, v) }
+
+func (w *writer) u32(v uint32) { w.buf = binary.LittleEndian.AppendUint32(w.buf, v) }
+
+func (w *writer) u64(v uint64) { w.buf = binary.LittleEndian.AppendUint64(w.buf, v) }
+
+func (w *writer) tag(t Tag) { w.buf = append(w.buf, t[:]...) }
+
+// count writes a one-byte element count.
+func (w *writer) count(n int, what string) {
+	if n > 0xff {
+		w.fail(fmt.Errorf("%d %s do not fit in a one-byte count", n, what))
+	}
+	w.u8(uint8(n))
+}
+
+func (w *writer) str8(s string) {
+	if len(s) > 0xff {
+		w.fail(fmt.Errorf("string %q is longer than 255 bytes", s))
+	}
+	w.u8(uint8(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *writer) str16(s string) {
+	if len(s) > 0xffff {
+		w.fail(fmt.Errorf("string of %d bytes is too long", len(s)))
+	}
+	w.u16(uint16(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *writer) ids(ids []uint16) {
+	w.count(len(ids), "ids")
+	for _, id := range ids {
+		w.u16(id)
+	}
+}
+
+func (w *writer) fail(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// chunk writes c with its tag and length header.
+func (w *writer) chunk(c Chunk) {
+	w.tag(c.Tag())
+	at := len(w.buf)
+	w.u32(0)
+	c.encode(w)
+	binary.LittleEndian.PutUint32(w.buf[at:], uint32(len(w.buf)-at-4))
+}
+
+// Marshal serializes the bank into the form Plugin.SetBankData expects.
+// Parsing a bank and marshaling it again yields the original bytes.
+func (b *Bank) Marshal() ([]byte, error) {
+	w := &writer{}
+	w.bytes([]byte(Magic))
+	w.u32(0)
+	w.str16(b.Version)
+	for _, c := range b.Chunks {
+		w.chunk(c)
+	}
+	if w.err != nil {
+		return nil, fmt.Errorf("ppsf: %w", w.err)
+	}
+	binary.LittleEndian.PutUint32(w.buf[len(Magic):], uint32(len(w.buf)-len(Magic)-4))
+	return w.buf, nil
+}
+
+// WriteFile marshals the bank and stores it at path.
+func (b *Bank) WriteFile(path string) error {
+	data, err := b.Marshal()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,58 @@
+package ppsf
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	for _, f := range fixtures {
+		t.Run(f.path, func(t *testing.T) {
+			data, err := os.ReadFile(f.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := b.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, data) {
+				t.Errorf("Marshal changed the bank: %d bytes in, %d bytes out", len(data), len(out))
+			}
+		})
+	}
+}
+
+// The phoneme lock byte is written back as read, not normalized to 0/1.
+func TestMarshalKeepsPhonemeLock(t *testing.T) {
+	b, err := ReadFile("../my_presetb.fxb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := b.VocalTracks()[0].Notes()[0]
+	n.PhonemeLock = 0x53
+	data, err := b.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n2 := b2.VocalTracks()[0].Notes()[0]
+	if n2.PhonemeLock != 0x53 || !n2.PhonemeLocked() {
+		t.Errorf("PhonemeLock = %#x after round trip, want 0x53", n2.PhonemeLock)
+	}
+	out, err := b2.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Error("second Marshal differs from the first")
+	}
+}
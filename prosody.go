@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/convert"
+	"github.com/isanan39s/PiaproStudio_TTS.git/ppsf"
+	"github.com/isanan39s/PiaproStudio_TTS.git/prosody"
+	"github.com/isanan39s/PiaproStudio_TTS.git/tempo"
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+// defaultSpeaker is the style get_Accents always used (ずんだもん/あまあま).
+const defaultSpeaker = "1"
+
+// prosodyConfig is the JSON file given with --config. Command line flags
+// override its fields.
+type prosodyConfig struct {
+	VoicevoxURL string `json:"voicevox_url"`
+	// Speaker is "speaker/style" (e.g. "ずんだもん/ノーマル") or a style id.
+	Speaker string `json:"speaker"`
+	// UserDict is a dictionary file synced to the engine at startup, see
+	// voicevox.LoadWords.
+	UserDict string `json:"user_dict"`
+	// Script is a hand-written prosody file used instead of the engine,
+	// see prosody.Script.
+	Script string `json:"script"`
+	// Phonemes is a phoneme table merged over the built-in one, see
+	// convert.LoadPhonemeTable.
+	Phonemes string `json:"phonemes"`
+	// QuestionRise and QuestionLength shape the rising end of
+	// interrogative phrases, in semitones and seconds; 0 means the default.
+	// NoQuestionRise keeps them flat. See convert.Options.
+	QuestionRise   float64 `json:"question_rise"`
+	QuestionLength float64 `json:"question_length"`
+	NoQuestionRise bool    `json:"no_question_rise"`
+	// Cache is the directory of cached engine answers, see prosody.Cache.
+	Cache         string `json:"cache"`
+	CacheReadOnly bool   `json:"cache_readonly"`
+	// CacheTTL is a duration such as "720h". Empty means no expiry.
+	CacheTTL string `json:"cache_ttl"`
+	// EngineVersion pins the engine version of the cache key. Together
+	// with a numeric Speaker it lets cached answers be used without any
+	// engine running, e.g. on CI.
+	EngineVersion string `json:"engine_version"`
+	// Tempo and TimeSigs are the tempo map the notes of generated banks are
+	// placed with, in quarter notes from the start. Without them the project
+	// is 120 BPM in 4/4. The bank keeps the tempo of its template, so set
+	// the same tempo there; the map itself only goes to <bank>.json.
+	Tempo    []tempo.Tempo   `json:"tempo"`
+	TimeSigs []tempo.TimeSig `json:"time_signatures"`
+}
+
+func loadConfig(path string) (prosodyConfig, error) {
+	var cfg prosodyConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("config %s: %w", path, err)
+	}
+	// 0 や負の値はテンポマップの割り算を壊すので読み込み時に弾く
+	for _, t := range cfg.Tempo {
+		if t.BPM <= 0 {
+			return cfg, fmt.Errorf("config %s: tempo at %v: invalid BPM %v", path, t.PPQ, t.BPM)
+		}
+	}
+	for _, s := range cfg.TimeSigs {
+		if s.Num <= 0 || s.Den <= 0 {
+			return cfg, fmt.Errorf("config %s: time signature at %v: invalid %d/%d", path, s.PPQ, s.Num, s.Den)
+		}
+	}
+	return cfg, nil
+}
+
+// tempoMap builds the tempo map shared by the converter and the host
+// transport. bpm, when set, replaces the tempo at the start.
+func (c prosodyConfig) tempoMap(bpm float64) *tempo.Map {
+	m := tempo.New(convert.DefaultTempo)
+	for _, t := range c.Tempo {
+		m.SetTempo(t.PPQ, t.BPM)
+	}
+	for _, s := range c.TimeSigs {
+		m.SetTimeSig(s.PPQ, s.Num, s.Den)
+	}
+	if bpm > 0 {
+		m.SetTempo(0, bpm)
+	}
+	return m
+}
+
+// convertOptions returns the conversion options of the config, placing the
+// notes through m.
+func (c prosodyConfig) convertOptions(m *tempo.Map) convert.Options {
+	return convert.Options{
+		TempoMap:       m,
+		QuestionRise:   c.QuestionRise,
+		QuestionLength: c.QuestionLength,
+		NoQuestionRise: c.NoQuestionRise,
+	}
+}
+
+// projectMeta is written next to a generated bank, as <bank>.json, so the
+// render can be reproduced with the same engine and style, or script.
+type projectMeta struct {
+	Text          string `json:"text"`
+	Kana          string `json:"kana"`
+	EngineURL     string `json:"engine_url,omitempty"`
+	EngineVersion string `json:"engine_version,omitempty"`
+	Speaker       string `json:"speaker,omitempty"`
+	Style         string `json:"style,omitempty"`
+	StyleID       int    `json:"style_id"`
+	Script        string `json:"script,omitempty"`
+	Phonemes      string `json:"phonemes,omitempty"`
+	// Tempo and TimeSigs are the tempo map the notes were placed with.
+	Tempo    []tempo.Tempo   `json:"tempo"`
+	TimeSigs []tempo.TimeSig `json:"time_signatures"`
+}
+
+// listSpeakers prints every style of the engine with its id.
+func listSpeakers(client *voicevox.Client) error {
+	speakers, err := client.Speakers(context.Background())
+	if err != nil {
+		return err
+	}
+	for i := range speakers {
+		for _, st := range speakers[i].Styles {
+			fmt.Printf("%4d  %s (%s)\n", st.ID, speakers[i].StyleName(st), st.Type)
+		}
+	}
+	return nil
+}
+
+// newBackend returns the prosody source: the script file when one is
+// given, the VOICEVOX engine with the named style otherwise. With kana set,
+// the engine takes accent-marked kana such as "テ'_キスト" and skips its
+// text analysis. meta describes the source.
+//
+// When version is set and speaker is a style id, the engine is not
+// contacted until a query misses the cache.
+func newBackend(client *voicevox.Client, speaker string, kana bool, script, version string) (prosody.Backend, projectMeta, error) {
+	if script != "" {
+		s, err := prosody.LoadScript(script)
+		return s, projectMeta{Script: script}, err
+	}
+	if id, err := strconv.Atoi(speaker); err == nil && version != "" {
+		return &prosody.VOICEVOX{Client: client, Style: id, Kana: kana, Version: version}, projectMeta{
+			EngineURL:     client.BaseURL,
+			EngineVersion: version,
+			StyleID:       id,
+		}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	version, err := client.WaitReady(ctx, time.Second)
+	if err != nil {
+		return nil, projectMeta{}, err
+	}
+	sp, style, err := client.FindStyle(ctx, speaker)
+	if err != nil {
+		return nil, projectMeta{}, err
+	}
+	fmt.Printf("VOICEVOX %s: %s (id %d)\n", version, sp.StyleName(*style), style.ID)
+	return &prosody.VOICEVOX{Client: client, Style: style.ID, Kana: kana, Version: version}, projectMeta{
+		EngineURL:     client.BaseURL,
+		EngineVersion: version,
+		Speaker:       sp.Name,
+		Style:         style.Name,
+		StyleID:       style.ID,
+	}, nil
+}
+
+// withCache puts a cache in front of backend. Expired answers are pruned
+// first unless the cache is read-only.
+func withCache(backend prosody.Backend, dir string, readOnly bool, ttl time.Duration) (prosody.Backend, error) {
+	c := &prosody.Cache{Backend: backend, Dir: dir, ReadOnly: readOnly, TTL: ttl}
+	if ttl > 0 && !readOnly {
+		n, err := c.Prune()
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			fmt.Printf("prosody cache %s: %d expired answers removed\n", dir, n)
+		}
+	}
+	return c, nil
+}
+
+// generateBank reads text with backend, puts its notes, converted with opt,
+// into the first vocal track of the template bank and writes the result to
+// out, and meta to out.json. Notes already in the template are removed from
+// every track, so the bank sings the text alone. The text starts where the
+// clip of that track starts. It returns the notes.
+func generateBank(backend prosody.Backend, meta projectMeta, opt convert.Options, text, template, out string) ([]convert.Note, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	q, err := backend.Query(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	bank, err := ppsf.ReadFile(template)
+	if err != nil {
+		return nil, err
+	}
+	tracks := bank.VocalTracks()
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("%s has no vocal track", template)
+	}
+	clips := tracks[0].Clips()
+	if len(clips) == 0 {
+		return nil, fmt.Errorf("%s has no clip on its first vocal track", template)
+	}
+	opt.ClipStart = clips[0].Start
+	notes := convert.FromQuery(q, opt)
+	// テンプレートに入っているノートは消してから書き込む
+	for _, t := range tracks {
+		t.ClearNotes()
+	}
+	for _, n := range convert.ToPPSF(notes, opt) {
+		if err := tracks[0].AddNote(n); err != nil {
+			return nil, err
+		}
+	}
+	if err := bank.WriteFile(out); err != nil {
+		return nil, err
+	}
+
+	meta.Text = text
+	meta.Kana = q.Kana
+	meta.Tempo = opt.TempoMap.Tempos()
+	meta.TimeSigs = opt.TempoMap.TimeSigs()
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return notes, os.WriteFile(out+".json", data, 0644)
+}
@@ -0,0 +1,42 @@
+// Package prosody provides the sources of the timing and pitch the
+// converter turns into notes. A Backend reads a line of text and answers
+// with accent phrases and moras in the layout of a VOICEVOX audio query.
+package prosody
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+// Backend turns text into phrases and moras.
+type Backend interface {
+	Query(ctx context.Context, text string) (*voicevox.ResponseData, error)
+}
+
+// VOICEVOX asks a VOICEVOX engine.
+type VOICEVOX struct {
+	Client *voicevox.Client
+	// Style is the style id, the "speaker" of the engine API.
+	Style int
+	// Kana makes Query take accent-marked kana such as "テ'_キスト"
+	// instead of text.
+	Kana    bool
+	Options voicevox.QueryOptions
+	// Version is the engine version, e.g. "0.14.0". It is only used in the
+	// cache key.
+	Version string
+}
+
+// CacheKey returns everything besides the text that changes the answer.
+func (v *VOICEVOX) CacheKey() string {
+	return fmt.Sprintf("voicevox %s style=%d kana=%t %+v", v.Version, v.Style, v.Kana, v.Options)
+}
+
+func (v *VOICEVOX) Query(ctx context.Context, text string) (*voicevox.ResponseData, error) {
+	if v.Kana {
+		return v.Client.AudioQueryFromKana(ctx, text, v.Style)
+	}
+	return v.Client.AudioQuery(ctx, text, v.Style, v.Options)
+}
@@ -0,0 +1,176 @@
+package prosody
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+// ErrMiss is returned by a Cache without a Backend for text it has not
+// stored.
+var ErrMiss = errors.New("prosody: not in cache")
+
+// Keyer is implemented by backends whose answers depend on more than the
+// text, e.g. on the speaker and the engine version. The key tells cached
+// answers for different settings apart.
+type Keyer interface {
+	CacheKey() string
+}
+
+// Cache keeps the answers of a backend on disk, one JSON file per text and
+// key, so repeated lines are not queried again and recorded answers can be
+// replayed without the backend.
+type Cache struct {
+	// Backend answers misses. nil makes every miss an ErrMiss.
+	Backend Backend
+	// Dir holds the cached answers.
+	Dir string
+	// Key identifies the settings of Backend. Empty means the CacheKey of
+	// Backend, if it is a Keyer.
+	Key string
+	// ReadOnly makes the cache serve stored answers without storing new
+	// ones, e.g. on shared recordings.
+	ReadOnly bool
+	// TTL is how long an answer stays valid. 0 means forever.
+	TTL time.Duration
+}
+
+// cacheEntry is the file layout of one cached answer.
+type cacheEntry struct {
+	Key      string                 `json:"key"`
+	Text     string                 `json:"text"`
+	Created  time.Time              `json:"created"`
+	Response *voicevox.ResponseData `json:"response"`
+}
+
+func (c *Cache) key() string {
+	if c.Key != "" {
+		return c.Key
+	}
+	if k, ok := c.Backend.(Keyer); ok {
+		return k.CacheKey()
+	}
+	return ""
+}
+
+// path returns the file of text: the SHA-256 of key and text.
+func (c *Cache) path(text string) string {
+	sum := sha256.Sum256([]byte(c.key() + "\x00" + text))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *Cache) Query(ctx context.Context, text string) (*voicevox.ResponseData, error) {
+	if q, err := c.Get(text); err == nil {
+		return q, nil
+	} else if !errors.Is(err, ErrMiss) {
+		return nil, err
+	}
+	if c.Backend == nil {
+		return nil, fmt.Errorf("%w: %q", ErrMiss, text)
+	}
+	q, err := c.Backend.Query(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	if !c.ReadOnly {
+		if err := c.Put(text, q); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// Get returns the stored answer for text. It fails with ErrMiss when there
+// is none or it has expired.
+func (c *Cache) Get(text string) (*voicevox.ResponseData, error) {
+	e, err := readEntry(c.path(text))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	// ハッシュが衝突していても別の答えは返さない
+	if e.Key != c.key() || e.Text != text || c.expired(e) {
+		return nil, ErrMiss
+	}
+	return e.Response, nil
+}
+
+// Put stores q as the answer for text, e.g. to seed the cache with a
+// recorded engine response.
+func (c *Cache) Put(text string, q *voicevox.ResponseData) error {
+	data, err := json.MarshalIndent(cacheEntry{Key: c.key(), Text: text, Created: time.Now(), Response: q}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	// 読み込み中に中途半端なファイルが見えないよう置き換える
+	tmp, err := os.CreateTemp(c.Dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(text))
+}
+
+// Prune deletes expired and unreadable entries of every key and returns how
+// many were deleted.
+func (c *Cache) Prune() (int, error) {
+	files, err := os.ReadDir(c.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(c.Dir, f.Name())
+		if e, err := readEntry(path); err == nil && !c.expired(e) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (c *Cache) expired(e *cacheEntry) bool {
+	return c.TTL > 0 && time.Since(e.Created) > c.TTL
+}
+
+func readEntry(path string) (*cacheEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	e := &cacheEntry{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, fmt.Errorf("prosody: cache %s: %w", path, err)
+	}
+	return e, nil
+}
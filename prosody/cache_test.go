@@ -0,0 +1,167 @@
+package prosody
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+// recorded replays testdata/audio_query.json, the engine answer for
+// "テキスト？", and counts the queries that reach it.
+type recorded struct {
+	q       *voicevox.ResponseData
+	queries int
+}
+
+func newRecorded(t *testing.T) *recorded {
+	t.Helper()
+	data, err := os.ReadFile("../testdata/audio_query.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &recorded{q: &voicevox.ResponseData{}}
+	if err := json.Unmarshal(data, r.q); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func (r *recorded) Query(ctx context.Context, text string) (*voicevox.ResponseData, error) {
+	r.queries++
+	return r.q, nil
+}
+
+func (r *recorded) CacheKey() string { return "recorded/1" }
+
+const text = "テキスト？"
+
+func TestCacheHitMiss(t *testing.T) {
+	backend := newRecorded(t)
+	c := &Cache{Backend: backend, Dir: t.TempDir()}
+	ctx := context.Background()
+
+	if _, err := c.Get(text); !errors.Is(err, ErrMiss) {
+		t.Fatalf("Get on an empty cache: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		q, err := c.Query(ctx, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Kana != backend.q.Kana {
+			t.Errorf("got kana %q", q.Kana)
+		}
+	}
+	if backend.queries != 1 {
+		t.Errorf("backend queried %d times, want 1", backend.queries)
+	}
+
+	// キーが違えば別の答えとして扱う
+	other := &Cache{Backend: backend, Dir: c.Dir, Key: "other"}
+	if _, err := other.Get(text); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get with another key: %v", err)
+	}
+}
+
+func TestCacheReadOnly(t *testing.T) {
+	backend := newRecorded(t)
+	c := &Cache{Backend: backend, Dir: t.TempDir(), ReadOnly: true}
+
+	if _, err := c.Query(context.Background(), text); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := os.ReadDir(c.Dir); len(files) != 0 {
+		t.Errorf("read-only cache wrote %d files", len(files))
+	}
+	if _, err := c.Get(text); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get after a read-only query: %v", err)
+	}
+}
+
+func TestCacheReplay(t *testing.T) {
+	dir := t.TempDir()
+	backend := newRecorded(t)
+	if err := (&Cache{Backend: backend, Dir: dir}).Put(text, backend.q); err != nil {
+		t.Fatal(err)
+	}
+
+	// エンジン無しで記録を再生する
+	c := &Cache{Dir: dir, Key: backend.CacheKey(), ReadOnly: true}
+	ctx := context.Background()
+	q, err := c.Query(ctx, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.AccentPhrases) != 1 || !q.AccentPhrases[0].IsInterrogative {
+		t.Errorf("replayed %+v", q.AccentPhrases)
+	}
+	if _, err := c.Query(ctx, "ほかの文"); !errors.Is(err, ErrMiss) {
+		t.Errorf("unrecorded text: got %v, want ErrMiss", err)
+	}
+}
+
+// age moves the stored entry of text d into the past.
+func age(t *testing.T, c *Cache, text string, d time.Duration) {
+	t.Helper()
+	e, err := readEntry(c.path(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Created = e.Created.Add(-d)
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.path(text), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	backend := newRecorded(t)
+	c := &Cache{Backend: backend, Dir: t.TempDir(), TTL: time.Hour}
+	ctx := context.Background()
+	if _, err := c.Query(ctx, text); err != nil {
+		t.Fatal(err)
+	}
+	age(t, c, text, 2*time.Hour)
+	if _, err := c.Get(text); !errors.Is(err, ErrMiss) {
+		t.Fatalf("Get of an expired entry: %v", err)
+	}
+	if _, err := c.Query(ctx, text); err != nil || backend.queries != 2 {
+		t.Fatalf("expired entry not queried again: %v, %d queries", err, backend.queries)
+	}
+	if _, err := c.Get(text); err != nil {
+		t.Fatalf("refreshed entry: %v", err)
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	backend := newRecorded(t)
+	c := &Cache{Backend: backend, Dir: t.TempDir(), TTL: time.Hour}
+	for _, s := range []string{"新しい", "古い"} {
+		if err := c.Put(s, backend.q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	age(t, c, "古い", 2*time.Hour)
+	if err := os.WriteFile(c.Dir+"/broken.json", []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := c.Prune()
+	if err != nil || n != 2 {
+		t.Fatalf("Prune removed %d entries (%v), want the expired and the broken one", n, err)
+	}
+	if _, err := c.Get("新しい"); err != nil {
+		t.Errorf("fresh entry pruned: %v", err)
+	}
+	if files, _ := os.ReadDir(c.Dir); len(files) != 1 {
+		t.Errorf("%d files left, want 1", len(files))
+	}
+}
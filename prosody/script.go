@@ -0,0 +1,121 @@
+package prosody
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
+)
+
+// Script serves prosody written by hand, so no engine is needed. A script
+// file is a JSON list of lines:
+//
+//	[{
+//	  "text": "テキスト",
+//	  "pre": 0.1, "post": 0.1,
+//	  "phrases": [{
+//	    "accent": 1,
+//	    "moras": [
+//	      {"text": "テ", "consonant": "t", "consonant_length": 0.07, "vowel": "e", "length": 0.12, "note": 66},
+//	      {"text": "キ", "consonant": "k", "consonant_length": 0.08, "vowel": "I", "length": 0.06}
+//	    ]
+//	  }]
+//	}]
+type Script struct {
+	Lines []ScriptLine
+}
+
+// ScriptLine is the prosody of one line of text.
+type ScriptLine struct {
+	Text    string         `json:"text"`
+	Phrases []ScriptPhrase `json:"phrases"`
+	// Pre and Post are the silence before and after the line in seconds.
+	Pre  float64 `json:"pre"`
+	Post float64 `json:"post"`
+}
+
+// ScriptPhrase is one accent phrase of a ScriptLine.
+type ScriptPhrase struct {
+	Moras []ScriptMora `json:"moras"`
+	// Accent is the mora after which the pitch falls. It is only used to
+	// fill in the kana of the query.
+	Accent int `json:"accent"`
+	// Pause is the silence after the phrase in seconds.
+	Pause float64 `json:"pause"`
+	// Question makes the phrase end rising, as "？" does.
+	Question bool `json:"question"`
+}
+
+// ScriptMora is one mora. Phonemes are VOICEVOX ones, see convert.PhonemeTable.
+type ScriptMora struct {
+	Text            string  `json:"text"`
+	Consonant       string  `json:"consonant"`
+	ConsonantLength float64 `json:"consonant_length"`
+	Vowel           string  `json:"vowel"`
+	Length          float64 `json:"length"` // vowel length in seconds
+	// Note is the MIDI note number, fractions allowed. 0 marks a devoiced
+	// mora.
+	Note float64 `json:"note"`
+}
+
+// LoadScript reads a script file.
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Script{}
+	if err := json.Unmarshal(data, &s.Lines); err != nil {
+		return nil, fmt.Errorf("prosody: script %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Query returns the line of the script whose text is text.
+func (s *Script) Query(ctx context.Context, text string) (*voicevox.ResponseData, error) {
+	for i := range s.Lines {
+		if s.Lines[i].Text == text {
+			return s.Lines[i].query(), nil
+		}
+	}
+	return nil, fmt.Errorf("prosody: no line %q in the script", text)
+}
+
+func (l *ScriptLine) query() *voicevox.ResponseData {
+	q := &voicevox.ResponseData{
+		SpeedScale:        1,
+		PrePhonemeLength:  l.Pre,
+		PostPhonemeLength: l.Post,
+	}
+	for _, p := range l.Phrases {
+		phrase := voicevox.AccentPhrase{Accent: p.Accent, IsInterrogative: p.Question}
+		for _, m := range p.Moras {
+			phrase.Moras = append(phrase.Moras, voicevox.Mora{
+				Text:            m.Text,
+				Consonant:       m.Consonant,
+				ConsonantLength: m.ConsonantLength,
+				Vowel:           m.Vowel,
+				VowelLength:     m.Length,
+				Pitch:           logHz(m.Note),
+			})
+		}
+		if p.Pause > 0 {
+			phrase.PauseMora = &voicevox.Mora{Text: "、", Vowel: "pau", VowelLength: p.Pause}
+		}
+		q.AccentPhrases = append(q.AccentPhrases, phrase)
+	}
+	q.Kana = voicevox.FormatKana(voicevox.Kana(q.AccentPhrases))
+	return q
+}
+
+// logHz converts a MIDI note number into the pitch of the engine, the
+// natural log of Hz. 0 stays 0.
+func logHz(note float64) float64 {
+	if note <= 0 {
+		return 0
+	}
+	return math.Log(440) + (note-69)/12*math.Ln2
+}
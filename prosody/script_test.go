@@ -0,0 +1,104 @@
+package prosody
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/convert"
+)
+
+func loadScript(t *testing.T) *Script {
+	t.Helper()
+	s, err := LoadScript("testdata/script.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestScriptQuery(t *testing.T) {
+	q, err := loadScript(t).Query(context.Background(), "テキスト")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Kana != "テ'_キ_スト" {
+		t.Errorf("kana = %q", q.Kana)
+	}
+	if q.PrePhonemeLength != 0.1 || q.PostPhonemeLength != 0.1 {
+		t.Errorf("pre/post = %v/%v, want 0.1/0.1", q.PrePhonemeLength, q.PostPhonemeLength)
+	}
+
+	// 台本の音高と長さがそのままノートになる
+	notes := convert.FromQuery(q, convert.Options{})
+	type want struct {
+		lyric string
+		start float64
+		pitch uint8
+	}
+	// 無声化したモーラは前の有声モーラの音高を借りる
+	wants := []want{{"て", 0.17, 66}, {"き", 0.37, 66}, {"す", 0.51, 66}, {"と", 0.64, 64}}
+	var got []convert.Note
+	for _, n := range notes {
+		if !n.Rest {
+			got = append(got, n)
+		}
+	}
+	if len(got) != len(wants) {
+		t.Fatalf("got %d notes, want %d", len(got), len(wants))
+	}
+	for i, w := range wants {
+		n := got[i]
+		if n.Lyric != w.lyric || math.Abs(n.Start-w.start) > 1e-9 || n.Pitch != w.pitch {
+			t.Errorf("note %d = %s at %v pitch %d, want %s at %v pitch %d",
+				i, n.Lyric, n.Start, n.Pitch, w.lyric, w.start, w.pitch)
+		}
+	}
+	if end := notes[len(notes)-1].End(); math.Abs(end-0.94) > 1e-9 {
+		t.Errorf("line ends at %v, want 0.94", end)
+	}
+}
+
+func TestScriptPhrases(t *testing.T) {
+	q, err := loadScript(t).Query(context.Background(), "ミク、ミク？")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Kana != "ミ'ク、ミ'ク？" {
+		t.Errorf("kana = %q", q.Kana)
+	}
+	if pm := q.AccentPhrases[0].PauseMora; pm == nil || q.Pause(*pm) != 0.3 {
+		t.Errorf("pause mora = %+v, want 0.3 s", pm)
+	}
+
+	var lyrics []string
+	for _, n := range convert.FromQuery(q, convert.Options{}) {
+		if n.Rest {
+			lyrics = append(lyrics, "_")
+		} else {
+			lyrics = append(lyrics, n.Lyric)
+		}
+	}
+	// 読点の休符と、疑問文の上がり調子のモーラが入る
+	if got, want := lyrics, []string{"み", "く", "_", "み", "く", "ー"}; !equal(got, want) {
+		t.Errorf("notes = %v, want %v", got, want)
+	}
+}
+
+func TestScriptMissingLine(t *testing.T) {
+	if _, err := loadScript(t).Query(context.Background(), "ない"); err == nil {
+		t.Error("Query found a line that is not in the script")
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
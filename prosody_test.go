@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigTempo(t *testing.T) {
+	tests := []struct {
+		json string
+		ok   bool
+	}{
+		{`{"tempo":[{"ppq":0,"bpm":90}],"time_signatures":[{"ppq":0,"num":3,"den":4}]}`, true},
+		{`{"tempo":[{"ppq":0,"bpm":0}]}`, false},
+		{`{"tempo":[{"ppq":4,"bpm":-120}]}`, false},
+		{`{"time_signatures":[{"ppq":0,"num":0,"den":4}]}`, false},
+		{`{"time_signatures":[{"ppq":0,"num":4,"den":0}]}`, false},
+		{`{"time_signatures":[{"ppq":0,"num":4,"den":-4}]}`, false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(tt.json), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadConfig(path)
+		if (err == nil) != tt.ok {
+			t.Errorf("loadConfig(%s) = %v, want ok %v", tt.json, err, tt.ok)
+		}
+	}
+}
+
+func TestConfigConvertOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"question_rise":4,"question_length":0.25,"no_question_rise":true}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := cfg.tempoMap(0)
+	opt := cfg.convertOptions(m)
+	if opt.TempoMap != m || opt.QuestionRise != 4 || opt.QuestionLength != 0.25 || !opt.NoQuestionRise {
+		t.Errorf("convertOptions = %+v", opt)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Defaults of renderOptions.
+const (
+	defaultTail         = 500 * time.Millisecond
+	defaultSilence      = -60.0 // dBFS
+	defaultSilentBlocks = 8
+	defaultTrimMargin   = 100 * time.Millisecond
+	// maxTail bounds the render after the tail when the output never
+	// falls silent, e.g. a plugin with a drone or a stuck note.
+	maxTail = 10 * time.Second
+)
+
+// renderOptions controls how long processAndSaveWav renders and what it
+// keeps of the output.
+type renderOptions struct {
+	// Length is the number of frames the sequence lasts, see sequenceLength.
+	Length int
+	// Events are sent to the plugin on their frames, see noteTimeline. The
+	// render lasts at least until the last of them.
+	Events []midiEvent
+	// Tail is rendered after Length so the last note can release.
+	// 0 means defaultTail.
+	Tail time.Duration
+	// Silence is the level in dBFS below which a block counts as silent.
+	// 0 means defaultSilence.
+	Silence float64
+	// SilentBlocks is how many silent blocks in a row end the render once
+	// Length and Tail are done. 0 means defaultSilentBlocks.
+	SilentBlocks int
+	// TrimMargin is the silence kept before the first and after the last
+	// audible sample. 0 means defaultTrimMargin.
+	TrimMargin time.Duration
+	// NoTrim keeps the leading and trailing silence.
+	NoTrim bool
+}
+
+func (o renderOptions) tail() int {
+	if o.Tail <= 0 {
+		return frames(defaultTail)
+	}
+	return frames(o.Tail)
+}
+
+// threshold returns Silence as a linear sample value.
+func (o renderOptions) threshold() float64 {
+	db := o.Silence
+	if db == 0 {
+		db = defaultSilence
+	}
+	return math.Pow(10, db/20)
+}
+
+func (o renderOptions) silentBlocks() int {
+	if o.SilentBlocks <= 0 {
+		return defaultSilentBlocks
+	}
+	return o.SilentBlocks
+}
+
+func (o renderOptions) trimMargin() int {
+	if o.TrimMargin <= 0 {
+		return frames(defaultTrimMargin)
+	}
+	return frames(o.TrimMargin)
+}
+
+// frames converts a duration into frames at the render rate.
+func frames(d time.Duration) int {
+	return int(math.Round(d.Seconds() * renderSampleRate))
+}
+
+// peak returns the largest absolute sample value.
+func peak(samples []float32) float64 {
+	p := 0.0
+	for _, s := range samples {
+		p = max(p, math.Abs(float64(s)))
+	}
+	return p
+}
+
+// trimSilence cuts the interleaved samples down to margin frames before the
+// first sample at or above threshold and margin frames after the last one.
+// Output that is silent throughout is kept whole.
+func trimSilence(samples []float32, channels int, threshold float64, margin int) []float32 {
+	first, last := -1, -1
+	for i, s := range samples {
+		if math.Abs(float64(s)) >= threshold {
+			if first < 0 {
+				first = i / channels
+			}
+			last = i / channels
+		}
+	}
+	if first < 0 {
+		return samples
+	}
+	n := len(samples) / channels
+	start := max(first-margin, 0)
+	end := min(last+1+margin, n)
+	return samples[start*channels : end*channels]
+}
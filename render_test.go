@@ -0,0 +1,233 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/go-audio/wav"
+	"pipelined.dev/audio/vst2"
+)
+
+// readWav returns the left channel of the 16-bit WAV at path.
+func readWav(t *testing.T, path string) []float64 {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	buf, err := wav.NewDecoder(f).FullPCMBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels := buf.Format.NumChannels
+	left := make([]float64, len(buf.Data)/channels)
+	for i := range left {
+		left[i] = float64(buf.Data[i*channels]) / 32767
+	}
+	return left
+}
+
+func TestRender(t *testing.T) {
+	const (
+		on, off = 4800, 14400 // どちらもブロックの途中
+		pitch   = 60
+	)
+	fake := newFakePlugin()
+	var level int64
+	total := int64(-1)
+	fake.OnDispatch = func(opcode vst2.PluginOpcode, index int32, value int64, ptr unsafe.Pointer, opt float32) uintptr {
+		switch opcode {
+		case vst2.PlugSetTotalSampleToProcess:
+			total = value
+		case vst2.PlugStartProcess:
+			level = hostCallback(vst2.HostGetCurrentProcessLevel, 0, 0, nil, 0)
+		}
+		return 0
+	}
+
+	path := filepath.Join(t.TempDir(), "out.wav")
+	err := processAndSaveWav(fake, path, renderOptions{
+		Length: off,
+		Events: []midiEvent{noteOn(on, 0, pitch, 100), noteOff(off, 0, pitch)},
+		NoTrim: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if level != int64(vst2.ProcessLevelOffline) {
+		t.Errorf("process level = %d, want offline", level)
+	}
+	if want := int64(off + frames(defaultTail)); total != want {
+		t.Errorf("total samples = %d, want %d", total, want)
+	}
+	if offlineRender.Load() {
+		t.Error("offline render still set after the render")
+	}
+
+	midi := fake.MIDI()
+	if len(midi) < 2 {
+		t.Fatalf("got %d MIDI events, want at least 2", len(midi))
+	}
+	if got, want := midi[0], (fakeMIDI{on, noteOn(0, 0, pitch, 100).Data}); got != want {
+		t.Errorf("note-on = %+v, want %+v", got, want)
+	}
+	if got, want := midi[1], (fakeMIDI{off, noteOff(0, 0, pitch).Data}); got != want {
+		t.Errorf("note-off = %+v, want %+v", got, want)
+	}
+	// 最後に全チャネルの all notes off が続く
+	if len(midi) != 2+16 {
+		t.Errorf("got %d MIDI events, want %d", len(midi), 2+16)
+	}
+
+	left := readWav(t, path)
+	if len(left) < off {
+		t.Fatalf("got %d frames, want at least %d", len(left), off)
+	}
+	if p := peakOf(left[:on]); p != 0 {
+		t.Errorf("peak before the note = %v, want silence", p)
+	}
+	if p := peakOf(left[off:]); p != 0 {
+		t.Errorf("peak after the note = %v, want silence", p)
+	}
+	held := left[on:off]
+	if p := peakOf(held); p < 0.1 {
+		t.Errorf("peak of the note = %v, want a sine", p)
+	}
+	// 零交差の数から周波数を求める
+	crossings := 0
+	for i := 1; i < len(held); i++ {
+		if (held[i-1] < 0) != (held[i] < 0) {
+			crossings++
+		}
+	}
+	hz := float64(crossings) / 2 / (float64(len(held)) / renderSampleRate)
+	want := 440 * math.Pow(2, float64(pitch-69)/12)
+	if math.Abs(hz-want) > 5 {
+		t.Errorf("frequency = %.1f Hz, want %.1f Hz", hz, want)
+	}
+}
+
+func peakOf(samples []float64) float64 {
+	p := 0.0
+	for _, s := range samples {
+		p = max(p, math.Abs(s))
+	}
+	return p
+}
+
+// renderNote renders a C4 from frame on to frame off with the fake plugin
+// and returns the left channel of the written file.
+func renderNote(t *testing.T, plugin Plugin, on, off int64, opt renderOptions) []float64 {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "out.wav")
+	opt.Length = int(off)
+	opt.Events = []midiEvent{noteOn(on, 0, 60, 100), noteOff(off, 0, 60)}
+	if err := processAndSaveWav(plugin, path, opt); err != nil {
+		t.Fatal(err)
+	}
+	return readWav(t, path)
+}
+
+func TestRenderTrim(t *testing.T) {
+	const on, off = 9600, 19200
+	margin := frames(defaultTrimMargin)
+	left := renderNote(t, newFakePlugin(), on, off, renderOptions{})
+
+	// 鳴っている区間の前後に余白だけ残る
+	want := off - on + 2*margin
+	if d := len(left) - want; d < -5 || d > 5 {
+		t.Errorf("got %d frames, want about %d", len(left), want)
+	}
+	if p := peakOf(left[:margin-5]); p != 0 {
+		t.Errorf("peak of the leading margin = %v, want silence", p)
+	}
+	if p := peakOf(left[margin+5 : margin+500]); p < 0.1 {
+		t.Errorf("peak after the leading margin = %v, want the note", p)
+	}
+
+	left = renderNote(t, newFakePlugin(), on, off, renderOptions{TrimMargin: 10 * time.Millisecond})
+	want = off - on + 2*frames(10*time.Millisecond)
+	if d := len(left) - want; d < -5 || d > 5 {
+		t.Errorf("got %d frames with a 10ms margin, want about %d", len(left), want)
+	}
+}
+
+func TestRenderSilentBlocks(t *testing.T) {
+	// 音が止まったブロック (4608-5120) の後、無音のブロックが続いたら止める
+	tests := []struct {
+		silentBlocks int
+		want         int
+	}{
+		{1, 11 * renderBufferSize},
+		{4, 14 * renderBufferSize},
+	}
+	for _, tt := range tests {
+		left := renderNote(t, newFakePlugin(), 0, 4800, renderOptions{
+			Tail:         time.Millisecond,
+			SilentBlocks: tt.silentBlocks,
+			NoTrim:       true,
+		})
+		if len(left) != tt.want {
+			t.Errorf("%d silent blocks: got %d frames, want %d", tt.silentBlocks, len(left), tt.want)
+		}
+	}
+}
+
+// dronePlugin never falls silent.
+type dronePlugin struct{ *fakePlugin }
+
+func (p dronePlugin) ProcessFloat(in, out vst2.FloatBuffer) {
+	for c := 0; c < 2; c++ { // processAndSaveWav はステレオ
+		ch := out.Channel(c)
+		for i := range ch {
+			ch[i] = 0.5
+		}
+	}
+}
+
+func TestRenderMaxTail(t *testing.T) {
+	left := renderNote(t, dronePlugin{newFakePlugin()}, 0, 4800, renderOptions{NoTrim: true})
+	if want := 4800 + frames(defaultTail) + frames(maxTail); len(left) != want {
+		t.Errorf("got %d frames, want the render cut at %d", len(left), want)
+	}
+}
+
+func TestTrimSilence(t *testing.T) {
+	// 2 チャネル。フレーム 3 の右と、フレーム 5 の左だけが鳴っている
+	samples := []float32{
+		0, 0,
+		0, 0,
+		0, 0.001,
+		0, 0.5,
+		0, 0,
+		-0.5, 0,
+		0, 0,
+		0, 0,
+		0, 0,
+	}
+	tests := []struct {
+		threshold   float64
+		margin      int
+		first, last int // 残るフレーム
+	}{
+		{0.1, 0, 3, 5},
+		{0.1, 1, 2, 6},
+		{0.1, 10, 0, 8},  // 端を超える余白は切り詰める
+		{0.001, 0, 2, 5}, // しきい値ちょうどは鳴っている
+		{1, 2, 0, 8},     // 全部無音ならそのまま
+	}
+	for _, tt := range tests {
+		got := trimSilence(samples, 2, tt.threshold, tt.margin)
+		want := samples[tt.first*2 : (tt.last+1)*2]
+		if !slices.Equal(got, want) {
+			t.Errorf("trimSilence(%v, %d) = %v, want frames %d-%d", tt.threshold, tt.margin, got, tt.first, tt.last)
+		}
+	}
+}
@@ -3,20 +3,40 @@
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
 	"github.com/go-audio/audio"
 	"github.com/go-audio/wav"
+	"github.com/isanan39s/PiaproStudio_TTS.git/convert"
+	"github.com/isanan39s/PiaproStudio_TTS.git/ppsf"
+	"github.com/isanan39s/PiaproStudio_TTS.git/tempo"
+	"github.com/isanan39s/PiaproStudio_TTS.git/voicevox"
 	"pipelined.dev/audio/vst2"
 )
 
+// The host runs the plugin at a fixed rate and block size.
+const (
+	renderSampleRate = 48000
+	renderBufferSize = 512
+)
+
+// offlineRender is set while processAndSaveWav runs, so hostCallback tells
+// the plugin it is rendering offline rather than playing in real time.
+var offlineRender atomic.Bool
+
 // デバッグ版 hostCallback: どの opcode でクラッシュするか特定用
 func hostCallback(op vst2.HostOpcode, index int32, value int64, ptr unsafe.Pointer, opt float32) int64 {
 	// This callback is noisy, so we'll comment it out for now.
@@ -26,15 +46,18 @@ func hostCallback(op vst2.HostOpcode, index int32, value int64, ptr unsafe.Point
 	case vst2.HostGetVendorVersion:
 		return 10
 	case vst2.HostGetSampleRate:
-		return int64(48000)
+		return int64(renderSampleRate)
 	case vst2.HostGetBufferSize:
-		return int64(512)
+		return int64(renderBufferSize)
 	case vst2.HostGetCurrentProcessLevel:
-		return int64(0)
+		if offlineRender.Load() {
+			return int64(vst2.ProcessLevelOffline)
+		}
+		return int64(vst2.ProcessLevelUnknown)
 	case vst2.HostGetTime:
-		return 0
+		return int64(uintptr(unsafe.Pointer(hostTransport.timeInfo(vst2.TimeInfoFlag(value)))))
 	case vst2.HostCanDo:
-		return 0
+		return int64(hostCanDo(vst2.HostCanDoString(cString(ptr))))
 	case vst2.HostOpcode(6): // hostWantMidi (opcode 6)
 		return 1
 	case vst2.HostGetVendorString, vst2.HostGetProductString:
@@ -50,6 +73,28 @@ func hostCallback(op vst2.HostOpcode, index int32, value int64, ptr unsafe.Point
 	}
 }
 
+// hostCanDo answers the HostCanDo queries of the plugin.
+func hostCanDo(s vst2.HostCanDoString) vst2.CanDoResponse {
+	switch s {
+	case vst2.HostCanOffline:
+		return vst2.YesCanDo
+	default:
+		return vst2.MaybeCanDo
+	}
+}
+
+// cString reads a NUL terminated string passed by the plugin.
+func cString(ptr unsafe.Pointer) string {
+	if ptr == nil {
+		return ""
+	}
+	var b []byte
+	for p := (*byte)(ptr); *p != 0 && len(b) < 256; p = (*byte)(unsafe.Add(unsafe.Pointer(p), 1)) {
+		b = append(b, *p)
+	}
+	return string(b)
+}
+
 func loadPlagin(path string) (*vst2.VST, *vst2.Plugin, map[string]int, error) {
 	fmt.Printf(" VST2 プラグインをロード中: %s\n", path)
 
@@ -98,7 +143,7 @@ func loadPlagin(path string) (*vst2.VST, *vst2.Plugin, map[string]int, error) {
 }
 
 // SaveFXB saves the plugin's state to an FXB file.
-func SaveFXB(plugin *vst2.Plugin, path string) error {
+func SaveFXB(plugin Plugin, path string) error {
 	plugin.Start()
 	data := plugin.GetBankData()
 	plugin.Suspend()
@@ -115,12 +160,32 @@ func SaveFXB(plugin *vst2.Plugin, path string) error {
 	return nil
 }
 
-func processAndSaveWav(plugin *vst2.Plugin, path string, duration time.Duration) error {
+// sequenceLength returns the length in frames of the sequence the plugin
+// holds, from the start of the project to the end of its last note.
+func sequenceLength(plugin Plugin, m *tempo.Map) (int, error) {
+	bank, err := ppsf.Parse(plugin.GetBankData())
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the sequence: %w", err)
+	}
+	end := bank.End()
+	if end == 0 {
+		return 0, errors.New("the sequence has no notes, set --duration")
+	}
+	return int(math.Ceil(m.Samples(float64(end)/ppsf.TicksPerQuarter, renderSampleRate))), nil
+}
+
+// processAndSaveWav renders the sequence offline, faster than real time,
+// with the transport playing from the start of the project, and writes it
+// to path. opt.Events are delivered on their frames, followed by an
+// all-notes-off. After the sequence and the release tail it keeps going
+// until the output has been silent for opt.SilentBlocks blocks, then trims
+// the silence at both ends.
+func processAndSaveWav(plugin Plugin, path string, opt renderOptions) error {
 	const (
-		sampleRate = 48000
+		sampleRate = renderSampleRate
 		channels   = 2
 		bitDepth   = 16
-		bufferSize = 512
+		bufferSize = renderBufferSize
 	)
 
 	// Create output file
@@ -133,16 +198,16 @@ func processAndSaveWav(plugin *vst2.Plugin, path string, duration time.Duration)
 	// Create WAV encoder
 	encoder := wav.NewEncoder(outFile, sampleRate, bitDepth, channels, 1) // 1 for PCM
 
-	// Create audio buffer
-	numSamples := int(duration.Seconds() * sampleRate)
-	intBuf := &audio.IntBuffer{
-		Format: &audio.Format{
-			NumChannels: channels,
-			SampleRate:  sampleRate,
-		},
-		Data:           make([]int, 0, numSamples*channels),
-		SourceBitDepth: bitDepth,
+	// MIDI はブロックごとに DeltaFrames を付けて送る
+	end := int64(opt.Length)
+	for _, e := range opt.Events {
+		end = max(end, e.Frame)
 	}
+	sched := newScheduler(slices.Concat(opt.Events, allNotesOff(end)))
+
+	// Create audio buffer
+	numSamples := int(end) + opt.tail()
+	samples := make([]float32, 0, numSamples*channels)
 
 	// Start plugin
 	plugin.SetSampleRate(sampleRate)
@@ -150,23 +215,28 @@ func processAndSaveWav(plugin *vst2.Plugin, path string, duration time.Duration)
 	plugin.Start()
 	defer plugin.Suspend()
 
-	// Send a MIDI note-on event to trigger sound
-	// MIDI Note On: channel 1, note C4 (60), velocity 100
-	noteOn := vst2.MIDIEvent{
-		DeltaFrames: 0,
-		Data:        [3]byte{0x90, 60, 100},
-	}
-	events := vst2.Events(&noteOn)
-	defer events.Free()
-	plugin.Dispatch(vst2.PlugProcessEvents, 0, 0, unsafe.Pointer(events), 0)
+	// 書き出しはオフライン処理として実時間より速く回す
+	offlineRender.Store(true)
+	defer offlineRender.Store(false)
+	plugin.Dispatch(vst2.PlugSetTotalSampleToProcess, 0, int64(numSamples), nil, 0)
+	plugin.Dispatch(vst2.PlugStartProcess, 0, 0, nil, 0)
+	defer plugin.Dispatch(vst2.PlugStopProcess, 0, 0, nil, 0)
+
+	// Play from the start; the transport follows every processed block and
+	// stops when the render is done
+	hostTransport.Locate(0)
+	hostTransport.Play(true)
+	defer hostTransport.Play(false)
 
 	// Process audio
-	fmt.Printf("Processing %.2f seconds of audio...\n", duration.Seconds())
-	remainingSamples := numSamples
-	for remainingSamples > 0 {
+	fmt.Printf("Processing %.2f seconds of audio...\n", float64(numSamples)/sampleRate)
+	threshold := opt.threshold()
+	limit := numSamples + frames(maxTail)
+	silent := 0 // 無音が続いたブロック数
+	for pos := 0; pos < limit; {
 		samplesToProcess := bufferSize
-		if samplesToProcess > remainingSamples {
-			samplesToProcess = remainingSamples
+		if samplesToProcess > limit-pos {
+			samplesToProcess = limit - pos
 		}
 
 		// Create VST buffers
@@ -174,115 +244,140 @@ func processAndSaveWav(plugin *vst2.Plugin, path string, duration time.Duration)
 		out := vst2.NewFloatBuffer(channels, samplesToProcess)
 
 		// Process audio
+		free := sched.send(plugin, int64(pos), samplesToProcess)
 		plugin.ProcessFloat(in, out)
+		free()
 
-		// Convert and append to buffer
+		// Append to buffer
+		block := len(samples)
 		for i := 0; i < samplesToProcess*channels; i++ {
-			sample := out.Channel(i % channels)[i/channels]
-			intBuf.Data = append(intBuf.Data, int(sample*32767.0))
+			samples = append(samples, out.Channel(i % channels)[i/channels])
 		}
 
 		in.Free()
 		out.Free()
-		remainingSamples -= samplesToProcess
+		hostTransport.Advance(samplesToProcess)
+		pos += samplesToProcess
+
+		if peak(samples[block:]) < threshold {
+			silent++
+		} else {
+			silent = 0
+		}
+		if pos >= numSamples && silent >= opt.silentBlocks() {
+			break
+		}
+	}
+
+	if !opt.NoTrim {
+		samples = trimSilence(samples, channels, threshold, opt.trimMargin())
+	}
+	intBuf := &audio.IntBuffer{
+		Format: &audio.Format{
+			NumChannels: channels,
+			SampleRate:  sampleRate,
+		},
+		Data:           make([]int, len(samples)),
+		SourceBitDepth: bitDepth,
+	}
+	for i, sample := range samples {
+		intBuf.Data[i] = int(sample * 32767.0)
 	}
 
 	// Write buffer to WAV file
 	if err := encoder.Write(intBuf); err != nil {
 		return fmt.Errorf("failed to write wav data: %w", err)
 	}
+	// Close は RIFF と data チャンクのサイズをヘッダーに書き戻す
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to finish wav file: %w", err)
+	}
 
-	fmt.Printf("Audio successfully written to %s\n", path)
+	fmt.Printf("Audio successfully written to %s (%.2f seconds)\n", path, float64(len(samples)/channels)/sampleRate)
 	return nil
 }
 
-func vstiPlaginRunner(host2vstiMessageChan chan string, vst *vst2.VST, plugin *vst2.Plugin, opcode map[string]int) {
+// pumpInterval is how often the runner pumps window messages and gives the
+// editor idle time while the plugin GUI is open.
+const pumpInterval = 10 * time.Millisecond
+
+// vstiPlaginRunner is the plugin worker. It locks itself to one OS thread,
+// since VST plugins expect all calls, and the window messages of their
+// editor, on the thread that opened them. It sleeps until a command
+// arrives or, while the GUI is open, the pump timer fires.
+func vstiPlaginRunner(requests <-chan request, plugin Plugin, opcode map[string]int) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
 	println("start plagin thead")
-	is_openWindow := false
-	var msg MSG
-	for {
-
-		if is_openWindow {
-			// PeekMessage: ノンブロッキングでメッセージをチェック
-			ret, _, _ := procPeekMessageW.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0, PM_REMOVE)
-
-			if ret > 0 {
-				// メッセージがあれば処理
-				if msg.Message == 0x0012 { // WM_QUIT
-					break
-				}
-				procTranslateMessage.Call(uintptr(unsafe.Pointer(&msg)))
-				procDispatchMessageW.Call(uintptr(unsafe.Pointer(&msg)))
-			} else {
-				// メッセージがなければ少し待機（CPU 負荷軽減）
-				procSleep.Call(100)
-			}
 
+	var pump <-chan time.Time // GUI を開くまでは nil で止めておく
+	var ticker *time.Ticker
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
 		}
+	}()
 
-		var msgFromHost []string
-		// println("get msg")
-		// if len(msgFromHost) <= 0 {
-		// 	println("contenyu-")
-		// 	continue
-		// }
-
+	for {
 		select {
-		case value, ok := <-host2vstiMessageChan:
-			if ok {
-				fmt.Println("値を取得しました:", value)
-				msgFromHost = strings.SplitN(value, ":", 2)
-			} else {
+		case req, ok := <-requests:
+			if !ok {
 				fmt.Println("チャネルは閉じられています。ループ終了。")
 				return // クローズされたらループを抜ける
 			}
-		default:
-			// データがなかった場合、少し待機してから次のループへ
-			//fmt.Println("データなし。少し待機してコンティニュー...")
-			//time.Sleep(100 * time.Millisecond) // ここで意図的に待機
-			continue
-		}
 
-		println("prosess msg")
-		switch msgFromHost[0] {
-		case "loadFXB":
-			if len(msgFromHost) >= 2 && msgFromHost[1] != "" {
-				fmt.Println("Loading .fxb:", msgFromHost[1])
-				data, err := ioutil.ReadFile(msgFromHost[1])
-				if err != nil {
-					log.Fatalf("Failed to read bank file: %v", err)
-				}
-				time.Sleep(200 * time.Millisecond)
+			var err error
+			switch c := req.cmd.(type) {
+			case loadFXBCommand:
+				err = loadFXB(plugin, c.Path)
 
-				plugin.SetBankData(data)
-				time.Sleep(200 * time.Millisecond)
+			case openGUICommand:
+				err = OpenPluginGUIWithWindow(plugin, opcode)
+				if err == nil && ticker == nil {
+					ticker = time.NewTicker(pumpInterval)
+					pump = ticker.C
+				}
 
-				fmt.Println("Bank set:", msgFromHost[1], "size", len(data))
-				//plugin.Suspend() // Suspend after setting data if not opening GUI
-			}
+			case saveFXBCommand:
+				err = SaveFXB(plugin, c.Path)
 
-		case "openGUI":
-			OpenPluginGUIWithWindow(plugin, opcode)
-			is_openWindow = true
-			time.Sleep(200 * time.Millisecond)
+			case callCommand:
+				err = c.Fn(plugin)
 
-		case "saveFXB":
-			if err := SaveFXB(plugin, msgFromHost[1]); err != nil {
-				log.Fatalf("Failed to save FXB file: %v", err)
+			default:
+				err = fmt.Errorf("unknown command %T", req.cmd)
 			}
+			req.reply <- err
+
+		case <-pump:
+			if !pumpWindowMessages() {
+				// ウィンドウが閉じられた (WM_QUIT)
+				ticker.Stop()
+				ticker, pump = nil, nil
+				continue
+			}
+			plugin.Dispatch(vst2.PlugEditIdle, 0, 0, nil, 0)
 		}
 	}
-
 }
 
-
-
 func main() {
-	host2vstiMessageChan := make(chan string, 127)
+	if len(os.Args) >= 2 && os.Args[1] == "dict" {
+		if err := runDict(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	var pluginPath, savePath, loadPath, outputWavPath string
-	var openGUI bool
-	duration := 5 * time.Second
+	var configPath, voicevoxURL, speaker, text, writePath, dictPath, scriptPath, phonemesPath string
+	var cacheDir, cacheTTL, engineVersion string
+	var openGUI, showSpeakers, kana, cacheReadOnly, noQuestionRise bool
+	var bpm float64
+	var renderOpt renderOptions
+	var sendMIDI bool
+	var controls []midiEvent // --cc と --nrpn。ノートより先に送る
+	var duration time.Duration // 0 ならシーケンスの終わりまで
 
 	// 引数処理
 	for i := 1; i < len(os.Args); i++ {
@@ -320,8 +415,173 @@ func main() {
 			} else {
 				log.Fatal("--duration requires a number of seconds")
 			}
+		case "--tail":
+			if i+1 < len(os.Args) {
+				d, err := time.ParseDuration(os.Args[i+1])
+				if err != nil {
+					log.Fatalf("invalid tail: %v", err)
+				}
+				renderOpt.Tail = d
+				i++ // consume value
+			} else {
+				log.Fatal("--tail requires a duration such as 500ms")
+			}
+		case "--silence":
+			if i+1 < len(os.Args) {
+				db, err := strconv.ParseFloat(os.Args[i+1], 64)
+				if err != nil || db >= 0 {
+					log.Fatalf("invalid silence level: %s", os.Args[i+1])
+				}
+				renderOpt.Silence = db
+				i++ // consume value
+			} else {
+				log.Fatal("--silence requires a level in dBFS such as -60")
+			}
+		case "--silent-blocks":
+			if i+1 < len(os.Args) {
+				n, err := strconv.Atoi(os.Args[i+1])
+				if err != nil || n <= 0 {
+					log.Fatalf("invalid number of silent blocks: %s", os.Args[i+1])
+				}
+				renderOpt.SilentBlocks = n
+				i++ // consume value
+			} else {
+				log.Fatal("--silent-blocks requires a number of blocks")
+			}
+		case "--trim-margin":
+			if i+1 < len(os.Args) {
+				d, err := time.ParseDuration(os.Args[i+1])
+				if err != nil {
+					log.Fatalf("invalid trim margin: %v", err)
+				}
+				renderOpt.TrimMargin = d
+				i++ // consume value
+			} else {
+				log.Fatal("--trim-margin requires a duration such as 100ms")
+			}
+		case "--midi":
+			sendMIDI = true
+		case "--cc":
+			if i+1 < len(os.Args) {
+				cc, value, err := parseControl(os.Args[i+1], 127, 127)
+				if err != nil {
+					log.Fatalf("invalid --cc: %v", err)
+				}
+				controls = append(controls, controlChange(0, 0, uint8(cc), uint8(value)))
+				i++ // consume value
+			} else {
+				log.Fatal("--cc requires <cc>=<value>")
+			}
+		case "--nrpn":
+			if i+1 < len(os.Args) {
+				param, value, err := parseControl(os.Args[i+1], 0x3fff, 0x3fff)
+				if err != nil {
+					log.Fatalf("invalid --nrpn: %v", err)
+				}
+				controls = append(controls, nrpn(0, 0, uint16(param), uint16(value))...)
+				i++ // consume value
+			} else {
+				log.Fatal("--nrpn requires <param>=<value>")
+			}
+		case "--no-trim":
+			renderOpt.NoTrim = true
 		case "--gui":
 			openGUI = true
+		case "--config":
+			if i+1 < len(os.Args) {
+				configPath = os.Args[i+1]
+				i++ // consume value
+			} else {
+				log.Fatal("--config requires a file path")
+			}
+		case "--voicevox":
+			if i+1 < len(os.Args) {
+				voicevoxURL = os.Args[i+1]
+				i++ // consume value
+			} else {
+				log.Fatal("--voicevox requires the engine URL")
+			}
+		case "--speaker":
+			if i+1 < len(os.Args) {
+				speaker = os.Args[i+1]
+				i++ // consume value
+			} else {
+				log.Fatal("--speaker requires a name like ずんだもん/ノーマル or a style id")
+			}
+		case "--phonemes":
+			if i+1 < len(os.Args) {
+				phonemesPath = os.Args[i+1]
+				i++ // consume value
+			} else {
+				log.Fatal("--phonemes requires a phoneme table file")
+			}
+		case "--no-question-rise":
+			noQuestionRise = true
+		case "--speakers":
+			showSpeakers = true
+		case "--text":
+			if i+1 < len(os.Args) {
+				text = os.Args[i+1]
+				i++ // consume value
+			} else {
+				log.Fatal("--text requires the text to read")
+			}
+		case "--dict":
+			if i+1 < len(os.Args) {
+				dictPath = os.Args[i+1]
+				i++ // consume value
+			} else {
+				log.Fatal("--dict requires a file path")
+			}
+		case "--script":
+			if i+1 < len(os.Args) {
+				scriptPath = os.Args[i+1]
+				i++ // consume value
+			} else {
+				log.Fatal("--script requires a file path")
+			}
+		case "--cache":
+			if i+1 < len(os.Args) {
+				cacheDir = os.Args[i+1]
+				i++ // consume value
+			} else {
+				log.Fatal("--cache requires a directory")
+			}
+		case "--cache-ttl":
+			if i+1 < len(os.Args) {
+				cacheTTL = os.Args[i+1]
+				i++ // consume value
+			} else {
+				log.Fatal("--cache-ttl requires a duration such as 720h")
+			}
+		case "--cache-readonly":
+			cacheReadOnly = true
+		case "--engine-version":
+			if i+1 < len(os.Args) {
+				engineVersion = os.Args[i+1]
+				i++ // consume value
+			} else {
+				log.Fatal("--engine-version requires a version such as 0.14.0")
+			}
+		case "--tempo":
+			if i+1 < len(os.Args) {
+				var err error
+				if bpm, err = strconv.ParseFloat(os.Args[i+1], 64); err != nil || bpm <= 0 {
+					log.Fatalf("invalid tempo: %s", os.Args[i+1])
+				}
+				i++ // consume value
+			} else {
+				log.Fatal("--tempo requires the BPM")
+			}
+		case "--kana":
+			kana = true
+		case "--write-fxb":
+			if i+1 < len(os.Args) {
+				writePath = os.Args[i+1]
+				i++ // consume value
+			} else {
+				log.Fatal("--write-fxb requires a file path")
+			}
 		default:
 			if !strings.HasPrefix(arg, "--") && pluginPath == "" {
 				pluginPath = arg
@@ -329,41 +589,144 @@ func main() {
 		}
 	}
 
-	if pluginPath == "" {
-		pluginPath = "c:\\Program Files\\Vstplugins\\Piapro Studio VSTi.dll" // Default plugin path
+	var cfg prosodyConfig
+	if configPath != "" {
+		var err error
+		if cfg, err = loadConfig(configPath); err != nil {
+			log.Fatalf("failed to load config: %v", err)
+		}
 	}
-
-	vst, plugin, opcodes, err := loadPlagin(pluginPath)
-	if err != nil {
-		log.Fatalf("failed to load plugin: %v", err)
+	if voicevoxURL == "" {
+		voicevoxURL = cfg.VoicevoxURL
+	}
+	if speaker == "" {
+		speaker = cfg.Speaker
+	}
+	if speaker == "" {
+		speaker = defaultSpeaker
+	}
+	if dictPath == "" {
+		dictPath = cfg.UserDict
+	}
+	if scriptPath == "" {
+		scriptPath = cfg.Script
+	}
+	if phonemesPath == "" {
+		phonemesPath = cfg.Phonemes
+	}
+	if cacheDir == "" {
+		cacheDir = cfg.Cache
+	}
+	if cacheTTL == "" {
+		cacheTTL = cfg.CacheTTL
+	}
+	if engineVersion == "" {
+		engineVersion = cfg.EngineVersion
+	}
+	cacheReadOnly = cacheReadOnly || cfg.CacheReadOnly
+	cfg.NoQuestionRise = cfg.NoQuestionRise || noQuestionRise
+	tempoMap := cfg.tempoMap(bpm)
+	hostTransport.SetTempoMap(tempoMap)
+	client := voicevox.NewClient(voicevoxURL)
+
+	/// 読み間違える単語をユーザー辞書に登録しておく
+	// 台本ならエンジンは使わない。バージョン固定ならキャッシュだけで済むこともある
+	if dictPath != "" && scriptPath == "" {
+		if err := syncDict(client, dictPath); err != nil {
+			if engineVersion == "" {
+				log.Fatalf("failed to sync user dictionary: %v", err)
+			}
+			log.Printf("user dictionary %s not synced, going on with the cache: %v", dictPath, err)
+		}
 	}
-	plugin.Start()
-	defer vst.Close()
-	defer plugin.Close()
-	time.Sleep(200 * time.Millisecond)
-	time.Sleep(200 * time.Millisecond)
 
-	go vstiPlaginRunner(host2vstiMessageChan, vst, plugin, opcodes)
-	time.Sleep(200 * time.Millisecond)
-	time.Sleep(200 * time.Millisecond)
+	if showSpeakers {
+		if err := listSpeakers(client); err != nil {
+			log.Fatalf("failed to list speakers: %v", err)
+		}
+		return
+	}
 
-	/// fxb投入
+	/// テキストから歌唱データを生成し、それを読み込ませる
+	if text != "" {
+		if loadPath == "" {
+			log.Fatal("--text requires --load-fxb as the template bank")
+		}
+		if writePath == "" {
+			writePath = "generated.fxb"
+		}
+		backend, meta, err := newBackend(client, speaker, kana, scriptPath, engineVersion)
+		if err != nil {
+			log.Fatalf("failed to set up prosody: %v", err)
+		}
+		if cacheDir != "" && scriptPath == "" {
+			var ttl time.Duration
+			if cacheTTL != "" {
+				if ttl, err = time.ParseDuration(cacheTTL); err != nil {
+					log.Fatalf("invalid cache TTL: %v", err)
+				}
+			}
+			if backend, err = withCache(backend, cacheDir, cacheReadOnly, ttl); err != nil {
+				log.Fatalf("failed to open prosody cache: %v", err)
+			}
+		}
+		opt := cfg.convertOptions(tempoMap)
+		/// 音素表の修正は再コンパイルせずにファイルで差し替える
+		if phonemesPath != "" {
+			if opt.Phonemes, err = convert.LoadPhonemeTable(phonemesPath); err != nil {
+				log.Fatalf("failed to load phoneme table: %v", err)
+			}
+			meta.Phonemes = phonemesPath
+		}
+		notes, err := generateBank(backend, meta, opt, text, loadPath, writePath)
+		if err != nil {
+			log.Fatalf("failed to generate bank: %v", err)
+		}
+		/// 自前でシーケンスを持たない音源にはノートを MIDI で送る
+		if sendMIDI {
+			renderOpt.Events = noteTimeline(notes, tempoMap)
+		}
+		loadPath = writePath
+	}
+	renderOpt.Events = append(controls, renderOpt.Events...)
 
-	go vstiPlaginRunner(host2vstiMessageChan, vst, plugin, opcodes)
+	if pluginPath == "" {
+		pluginPath = "c:\\Program Files\\Vstplugins\\Piapro Studio VSTi.dll" // Default plugin path
+	}
 
+	// プラグインはワーカーのスレッドでロードする
+	sess := newSession(func() (io.Closer, Plugin, map[string]int, error) {
+		if pluginPath == fakePluginPath {
+			return nil, newFakePlugin(), nil, nil
+		}
+		vst, plugin, opcodes, err := loadPlagin(pluginPath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return vst, plugin, opcodes, nil
+	})
+	if err := sess.Start(); err != nil {
+		log.Fatalf("failed to start plugin worker: %v", err)
+	}
+	defer sess.Close()
+	if err := sess.Call(func(p Plugin) error {
+		p.Start()
+		return nil
+	}); err != nil {
+		log.Fatalf("failed to start plugin: %v", err)
+	}
 	/// fxb投入
 	if loadPath != "" {
-		var massage_source = []string{"loadFXB", loadPath}
-		host2vstiMessageChan <- strings.Join(massage_source, ":")
-		println(strings.Join(massage_source, ":"))
+		if err := sess.Do(loadFXBCommand{Path: loadPath}); err != nil {
+			log.Fatalf("Failed to load FXB file: %v", err)
+		}
 	}
 
 	/// ウィンドウ召喚
 	if openGUI {
-
-		host2vstiMessageChan <- "openGUI"
-		println("openGUI")
-
+		if err := sess.Do(openGUICommand{}); err != nil {
+			log.Fatalf("Failed to open GUI: %v", err)
+		}
 	}
 	println("enter to save parmetors")
 
@@ -371,17 +734,27 @@ func main() {
 
 	/// fxb出力 Enterで
 	if savePath != "" {
-
-		var massage_source = []string{"saveFXB", loadPath}
-		host2vstiMessageChan <- strings.Join(massage_source, ":")
-		println(strings.Join(massage_source, ":"))
+		if err := sess.Do(saveFXBCommand{Path: savePath}); err != nil {
+			log.Fatalf("Failed to save FXB file: %v", err)
+		}
 	}
 
 	println("enter to save wave")
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
 	// Process and save WAV if requested
 	if outputWavPath != "" {
-		if err := processAndSaveWav(plugin, outputWavPath, duration); err != nil {
+		render := func(p Plugin) error {
+			opt := renderOpt
+			opt.Length = frames(duration)
+			if opt.Length == 0 {
+				var err error
+				if opt.Length, err = sequenceLength(p, tempoMap); err != nil {
+					return err
+				}
+			}
+			return processAndSaveWav(p, outputWavPath, opt)
+		}
+		if err := sess.Call(render); err != nil {
 			log.Fatalf("Failed to process and save WAV: %v", err)
 		}
 	}
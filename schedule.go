@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/convert"
+	"github.com/isanan39s/PiaproStudio_TTS.git/ppsf"
+	"github.com/isanan39s/PiaproStudio_TTS.git/tempo"
+	"pipelined.dev/audio/vst2"
+)
+
+// midiEvent is a MIDI channel message at an absolute sample position of the
+// render.
+type midiEvent struct {
+	Frame int64
+	Data  [3]byte
+}
+
+func noteOn(frame int64, channel, note, velocity uint8) midiEvent {
+	return midiEvent{frame, [3]byte{0x90 | channel&0x0f, note & 0x7f, velocity & 0x7f}}
+}
+
+func noteOff(frame int64, channel, note uint8) midiEvent {
+	return midiEvent{frame, [3]byte{0x80 | channel&0x0f, note & 0x7f, 0}}
+}
+
+func controlChange(frame int64, channel, cc, value uint8) midiEvent {
+	return midiEvent{frame, [3]byte{0xB0 | channel&0x0f, cc & 0x7f, value & 0x7f}}
+}
+
+func pitchBend(frame int64, channel uint8, value int16) midiEvent {
+	return midiEvent{frame, convert.BendMessage(channel, value)}
+}
+
+// nrpn sets a 14-bit NRPN parameter: CC 99/98 select it, CC 6/38 carry the
+// value.
+func nrpn(frame int64, channel uint8, param, value uint16) []midiEvent {
+	return []midiEvent{
+		controlChange(frame, channel, 99, uint8(param>>7)),
+		controlChange(frame, channel, 98, uint8(param)),
+		controlChange(frame, channel, 6, uint8(value>>7)),
+		controlChange(frame, channel, 38, uint8(value)),
+	}
+}
+
+// parseControl parses a "<number>=<value>" option such as the ones of --cc
+// and --nrpn, checking both against their largest value.
+func parseControl(s string, maxNumber, maxValue int) (number, value int, err error) {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return 0, 0, fmt.Errorf("%q is not <number>=<value>", s)
+	}
+	if number, err = strconv.Atoi(k); err != nil || number < 0 || number > maxNumber {
+		return 0, 0, fmt.Errorf("%q: number must be 0-%d", s, maxNumber)
+	}
+	if value, err = strconv.Atoi(v); err != nil || value < 0 || value > maxValue {
+		return 0, 0, fmt.Errorf("%q: value must be 0-%d", s, maxValue)
+	}
+	return number, value, nil
+}
+
+// allNotesOff releases every note on all 16 channels.
+func allNotesOff(frame int64) []midiEvent {
+	events := make([]midiEvent, 0, 16)
+	for ch := uint8(0); ch < 16; ch++ {
+		events = append(events, controlChange(frame, ch, 123, 0))
+	}
+	return events
+}
+
+// noteTimeline turns notes into MIDI on channel 1: the bend range, then the
+// notes and their pitch bend, placed on the samples the bank puts them at
+// through m.
+func noteTimeline(notes []convert.Note, m *tempo.Map) []midiEvent {
+	frame := func(sec float64) int64 {
+		return int64(m.Samples(float64(m.Ticks(sec))/ppsf.TicksPerQuarter, renderSampleRate))
+	}
+	opt := convert.Options{TempoMap: m}
+	opt.BendSensitivity = convert.BendRange(notes, opt)
+	var events []midiEvent
+	for _, data := range convert.SensitivityMessages(0, opt.BendSensitivity) {
+		events = append(events, midiEvent{0, data})
+	}
+	for _, b := range convert.PitchBend(notes, opt) {
+		events = append(events, pitchBend(frame(b.Time), 0, b.Value))
+	}
+	for _, n := range notes {
+		if n.Rest {
+			continue
+		}
+		vel := n.Velocity
+		if vel == 0 {
+			vel = ppsf.DefaultVelocity
+		}
+		events = append(events, noteOn(frame(n.Start), 0, n.Pitch, vel), noteOff(frame(n.End()), 0, n.Pitch))
+	}
+	return events
+}
+
+// scheduler hands the events of a timeline to the plugin block by block.
+type scheduler struct {
+	events []midiEvent
+	next   int
+}
+
+// newScheduler sorts the timeline by frame. Events on the same frame keep
+// their order, so the note-off of a legato note still goes out before the
+// note-on of the next one.
+func newScheduler(events []midiEvent) *scheduler {
+	events = slices.Clone(events)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Frame < events[j].Frame })
+	return &scheduler{events: events}
+}
+
+// block returns the events of the frames [start, start+frames) with their
+// offset into the block as DeltaFrames. Events before start that were not
+// delivered yet go at the start of the block.
+func (s *scheduler) block(start int64, frames int) []vst2.MIDIEvent {
+	var out []vst2.MIDIEvent
+	for ; s.next < len(s.events) && s.events[s.next].Frame < start+int64(frames); s.next++ {
+		e := s.events[s.next]
+		out = append(out, vst2.MIDIEvent{DeltaFrames: int32(max(e.Frame-start, 0)), Data: e.Data})
+	}
+	return out
+}
+
+// send delivers the events of one block with PlugProcessEvents. The
+// returned function frees them; call it after the block has been processed.
+func (s *scheduler) send(plugin Plugin, start int64, frames int) (free func()) {
+	midi := s.block(start, frames)
+	if len(midi) == 0 {
+		return func() {}
+	}
+	// プラグインは処理が終わるまで C 側からイベントを参照する
+	var pinner runtime.Pinner
+	events := make([]vst2.Event, len(midi))
+	for i := range midi {
+		pinner.Pin(&midi[i])
+		events[i] = &midi[i]
+	}
+	ptr := vst2.Events(events...)
+	plugin.Dispatch(vst2.PlugProcessEvents, 0, 0, unsafe.Pointer(ptr), 0)
+	return func() {
+		ptr.Free()
+		pinner.Unpin()
+	}
+}
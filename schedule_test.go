@@ -0,0 +1,97 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"pipelined.dev/audio/vst2"
+)
+
+func TestSchedulerBlock(t *testing.T) {
+	const block = renderBufferSize
+	s := newScheduler([]midiEvent{
+		noteOn(block+10, 0, 62, 100), // 2 つ目のブロック
+		noteOn(0, 0, 60, 100),
+		noteOff(block-1, 0, 60), // 最初のブロックの最後のフレーム
+		noteOff(block+10, 0, 62),
+		noteOn(block+10, 0, 64, 100), // 同じフレームは渡した順を保つ
+	})
+	type want struct {
+		delta int32
+		data  [3]byte
+	}
+	tests := []struct {
+		start int64
+		want  []want
+	}{
+		{0, []want{{0, noteOn(0, 0, 60, 100).Data}, {block - 1, noteOff(0, 0, 60).Data}}},
+		{block, []want{
+			{10, noteOn(0, 0, 62, 100).Data},
+			{10, noteOff(0, 0, 62).Data},
+			{10, noteOn(0, 0, 64, 100).Data},
+		}},
+		{2 * block, nil},
+	}
+	for _, tt := range tests {
+		var got []want
+		for _, e := range s.block(tt.start, block) {
+			got = append(got, want{e.DeltaFrames, e.Data})
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("block at %d = %v, want %v", tt.start, got, tt.want)
+		}
+	}
+}
+
+func TestSchedulerLate(t *testing.T) {
+	// 最初のブロックを飛ばしても、取り残したイベントは次のブロックの頭で渡す
+	s := newScheduler([]midiEvent{noteOn(5, 0, 60, 100), noteOn(600, 0, 62, 100)})
+	got := s.block(512, 512)
+	want := []vst2.MIDIEvent{
+		{DeltaFrames: 0, Data: noteOn(0, 0, 60, 100).Data},
+		{DeltaFrames: 88, Data: noteOn(0, 0, 62, 100).Data},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].DeltaFrames != want[i].DeltaFrames || got[i].Data != want[i].Data {
+			t.Errorf("event %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestNRPN(t *testing.T) {
+	got := nrpn(7, 1, 0x1234, 0x0abc)
+	want := []midiEvent{
+		{7, [3]byte{0xb1, 99, 0x24}},
+		{7, [3]byte{0xb1, 98, 0x34}},
+		{7, [3]byte{0xb1, 6, 0x15}},
+		{7, [3]byte{0xb1, 38, 0x3c}},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("nrpn = %v, want %v", got, want)
+	}
+}
+
+func TestParseControl(t *testing.T) {
+	tests := []struct {
+		s             string
+		number, value int
+		ok            bool
+	}{
+		{"7=100", 7, 100, true},
+		{"0=0", 0, 0, true},
+		{"128=0", 0, 0, false},
+		{"7=128", 0, 0, false},
+		{"-1=0", 0, 0, false},
+		{"7", 0, 0, false},
+		{"a=1", 0, 0, false},
+	}
+	for _, tt := range tests {
+		number, value, err := parseControl(tt.s, 127, 127)
+		if (err == nil) != tt.ok || number != tt.number || value != tt.value {
+			t.Errorf("parseControl(%q) = %d, %d, %v", tt.s, number, value, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+var (
+	errAttached      = errors.New("plugin session: a worker is already attached")
+	errNotStarted    = errors.New("plugin session: not started")
+	errSessionClosed = errors.New("plugin session: closed")
+)
+
+// openFunc loads a plugin. lib is the plugin library, nil for fakePlugin.
+type openFunc func() (lib io.Closer, plugin Plugin, opcodes map[string]int, err error)
+
+// session owns a plugin and the one worker allowed to call into it. VST
+// instances are not thread-safe, so every call goes through the commands
+// of the session instead of touching the plugin directly. The worker keeps
+// its OS thread from loading the plugin until Close, so the plugin never
+// sees another thread.
+type session struct {
+	open    openFunc
+	lib     io.Closer // set by the first Start
+	plugin  Plugin
+	opcodes map[string]int
+
+	mu       sync.RWMutex
+	requests chan request  // nil until the first Start and after Close
+	done     chan struct{} // closed when the worker has returned
+	attached bool          // whether Do may send to the worker
+	closed   bool
+}
+
+// newSession returns a session that loads its plugin with open when it is
+// first started.
+func newSession(open openFunc) *session {
+	return &session{open: open}
+}
+
+// Start attaches callers to the worker. The first Start runs the worker and
+// loads the plugin on its thread. It fails if the session is already
+// started or the plugin cannot be loaded.
+func (s *session) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.closed:
+		return errSessionClosed
+	case s.attached:
+		return errAttached
+	}
+	if s.requests == nil {
+		if err := s.spawn(); err != nil {
+			return err
+		}
+	}
+	s.attached = true
+	return nil
+}
+
+// spawn runs the worker and loads the plugin on its thread.
+func (s *session) spawn() error {
+	requests := make(chan request, 127)
+	done := make(chan struct{})
+	opened := make(chan error, 1)
+	go func() {
+		defer close(done)
+		// ロードから Close までプラグインを同じスレッドに留める
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		lib, plugin, opcodes, err := s.open()
+		if err != nil {
+			opened <- err
+			return
+		}
+		s.lib, s.plugin, s.opcodes = lib, plugin, opcodes
+		opened <- nil
+		vstiPlaginRunner(requests, plugin, opcodes)
+	}()
+	if err := <-opened; err != nil {
+		<-done
+		return fmt.Errorf("plugin session: load plugin: %w", err)
+	}
+	s.requests, s.done = requests, done
+	return nil
+}
+
+// Do sends cmd to the worker and waits for its result.
+func (s *session) Do(cmd command) error {
+	s.mu.RLock()
+	if !s.attached {
+		defer s.mu.RUnlock()
+		if s.closed {
+			return errSessionClosed
+		}
+		return errNotStarted
+	}
+	// 送信中に Close がチャネルを閉じないよう読み取りロックを保持する
+	reply := make(chan error, 1)
+	s.requests <- request{cmd, reply}
+	s.mu.RUnlock()
+	return <-reply
+}
+
+// Call runs fn on the worker thread and waits for it.
+func (s *session) Call(fn func(plugin Plugin) error) error {
+	return s.Do(callCommand{Fn: fn})
+}
+
+// Stop detaches callers from the worker once it has finished the commands
+// already sent. The worker keeps the plugin and its thread, so the session
+// can be started again.
+func (s *session) Stop() error {
+	s.mu.Lock()
+	if !s.attached {
+		s.mu.Unlock()
+		return errNotStarted
+	}
+	s.attached = false
+	// チャネルは順番通りに処理されるので、空のコマンドの返事で先行分の完了がわかる
+	reply := make(chan error, 1)
+	s.requests <- request{callCommand{Fn: func(Plugin) error { return nil }}, reply}
+	s.mu.Unlock()
+	<-reply
+	return nil
+}
+
+// Close closes the plugin on the worker thread, ends the worker and unloads
+// the plugin library. The session cannot be used afterwards.
+func (s *session) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errSessionClosed
+	}
+	requests, done := s.requests, s.done
+	s.closed, s.attached = true, false
+	s.requests, s.done = nil, nil
+	s.mu.Unlock()
+	if requests == nil {
+		return nil // プラグインはロードされていない
+	}
+	reply := make(chan error, 1)
+	requests <- request{callCommand{Fn: func(p Plugin) error {
+		p.Close()
+		return nil
+	}}, reply}
+	close(requests)
+	<-reply
+	<-done
+	if s.lib == nil {
+		return nil
+	}
+	return s.lib.Close()
+}
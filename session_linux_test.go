@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// TestSessionThread checks that the plugin is loaded, used and closed on one
+// OS thread, also across Stop and Start.
+func TestSessionThread(t *testing.T) {
+	var mu sync.Mutex
+	threads := map[int]bool{}
+	seen := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		threads[syscall.Gettid()] = true
+	}
+	fake := newFakePlugin()
+	sess := newSession(func() (io.Closer, Plugin, map[string]int, error) {
+		seen()
+		return nil, &threadPlugin{fake, seen}, nil, nil
+	})
+	call := func() {
+		t.Helper()
+		if err := sess.Call(func(Plugin) error {
+			seen()
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sess.Start(); err != nil {
+		t.Fatal(err)
+	}
+	call()
+	sess.Stop()
+	if err := sess.Start(); err != nil {
+		t.Fatal(err)
+	}
+	call()
+	sess.Stop()
+	// 止めたセッションの Close もワーカーのスレッドで閉じる
+	if err := sess.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !fake.closed {
+		t.Fatal("plugin was not closed")
+	}
+	if len(threads) != 1 {
+		t.Errorf("plugin used on %d threads, want 1", len(threads))
+	}
+}
+
+// threadPlugin reports the thread Close runs on.
+type threadPlugin struct {
+	*fakePlugin
+	seen func()
+}
+
+func (p *threadPlugin) Close() {
+	p.seen()
+	p.fakePlugin.Close()
+}
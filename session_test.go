@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeSession returns a started session around a fakePlugin.
+func fakeSession(t *testing.T) (*session, *fakePlugin) {
+	t.Helper()
+	fake := newFakePlugin()
+	sess := newSession(func() (io.Closer, Plugin, map[string]int, error) {
+		return nil, fake, nil, nil
+	})
+	if err := sess.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return sess, fake
+}
+
+func TestSessionConcurrent(t *testing.T) {
+	sess, fake := fakeSession(t)
+	defer sess.Close()
+
+	// calls は排他制御なしで書き換える。ワーカーが直列化していなければ -race が検出する
+	calls := 0
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				err := sess.Call(func(p Plugin) error {
+					calls++
+					p.SetParamValue(g%p.NumParams(), float32(i))
+					return nil
+				})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if err := sess.Do(loadFXBCommand{Path: "my_preset.fxb"}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	var got int
+	sess.Call(func(Plugin) error {
+		got = calls
+		return nil
+	})
+	if got != 8*50 {
+		t.Errorf("calls = %d, want %d", got, 8*50)
+	}
+	if len(fake.GetBankData()) == 0 {
+		t.Error("bank was not loaded")
+	}
+}
+
+func TestSessionCallError(t *testing.T) {
+	sess, _ := fakeSession(t)
+	defer sess.Close()
+
+	want := errors.New("boom")
+	if err := sess.Call(func(Plugin) error { return want }); err != want {
+		t.Errorf("Call = %v, want %v", err, want)
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	sess, fake := fakeSession(t)
+
+	if err := sess.Start(); err != errAttached {
+		t.Errorf("second Start = %v, want errAttached", err)
+	}
+	if err := sess.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.Call(func(Plugin) error { return nil }); err != errNotStarted {
+		t.Errorf("Call after Stop = %v, want errNotStarted", err)
+	}
+	// 止めてもワーカーはプラグインを持ったまま残り、付け直せる
+	if err := sess.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !fake.closed {
+		t.Error("plugin was not closed")
+	}
+	if err := sess.Do(openGUICommand{}); err != errSessionClosed {
+		t.Errorf("Do after Close = %v, want errSessionClosed", err)
+	}
+	if err := sess.Start(); err != errSessionClosed {
+		t.Errorf("Start after Close = %v, want errSessionClosed", err)
+	}
+	if err := sess.Close(); err != errSessionClosed {
+		t.Errorf("second Close = %v, want errSessionClosed", err)
+	}
+}
+
+func TestSessionOpenError(t *testing.T) {
+	want := errors.New("no such plugin")
+	sess := newSession(func() (io.Closer, Plugin, map[string]int, error) {
+		return nil, nil, nil, want
+	})
+	if err := sess.Start(); !errors.Is(err, want) {
+		t.Fatalf("Start = %v, want %v", err, want)
+	}
+	if err := sess.Call(func(Plugin) error { return nil }); err != errNotStarted {
+		t.Errorf("Call after failed Start = %v, want errNotStarted", err)
+	}
+}
+
+func TestSessionCloseStopped(t *testing.T) {
+	sess, fake := fakeSession(t)
+	if err := sess.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !fake.closed {
+		t.Error("plugin was not closed")
+	}
+}
+
+func TestSessionCloseUnstarted(t *testing.T) {
+	opened := false
+	sess := newSession(func() (io.Closer, Plugin, map[string]int, error) {
+		opened = true
+		return nil, newFakePlugin(), nil, nil
+	})
+	if err := sess.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if opened {
+		t.Error("Close loaded the plugin")
+	}
+}
@@ -0,0 +1,143 @@
+// Package tempo maps between the time axes the host deals with: seconds of
+// the VOICEVOX lengths, samples of the render, quarter notes (PPQ) of the
+// VST transport and the ticks of PPSF banks.
+package tempo
+
+import (
+	"math"
+	"sort"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/ppsf"
+)
+
+// Tempo is a tempo change at a position in quarter notes.
+type Tempo struct {
+	PPQ float64 `json:"ppq"`
+	BPM float64 `json:"bpm"`
+}
+
+// TimeSig is a time signature change. It should be placed on a bar line.
+type TimeSig struct {
+	PPQ float64 `json:"ppq"`
+	Num int     `json:"num"`
+	Den int     `json:"den"`
+}
+
+// Map holds the tempo and time signature changes of a project. There is
+// always a tempo and a time signature at position 0.
+type Map struct {
+	tempos []Tempo
+	sigs   []TimeSig
+}
+
+// New returns a map with a constant tempo in 4/4.
+func New(bpm float64) *Map {
+	return &Map{
+		tempos: []Tempo{{0, bpm}},
+		sigs:   []TimeSig{{0, 4, 4}},
+	}
+}
+
+// SetTempo changes the tempo from ppq on, replacing a change at the same
+// position.
+func (m *Map) SetTempo(ppq, bpm float64) {
+	i := sort.Search(len(m.tempos), func(i int) bool { return m.tempos[i].PPQ >= ppq })
+	if i < len(m.tempos) && m.tempos[i].PPQ == ppq {
+		m.tempos[i].BPM = bpm
+		return
+	}
+	m.tempos = append(m.tempos, Tempo{})
+	copy(m.tempos[i+1:], m.tempos[i:])
+	m.tempos[i] = Tempo{ppq, bpm}
+}
+
+// SetTimeSig changes the time signature from ppq on, replacing a change at
+// the same position.
+func (m *Map) SetTimeSig(ppq float64, num, den int) {
+	i := sort.Search(len(m.sigs), func(i int) bool { return m.sigs[i].PPQ >= ppq })
+	if i < len(m.sigs) && m.sigs[i].PPQ == ppq {
+		m.sigs[i].Num, m.sigs[i].Den = num, den
+		return
+	}
+	m.sigs = append(m.sigs, TimeSig{})
+	copy(m.sigs[i+1:], m.sigs[i:])
+	m.sigs[i] = TimeSig{ppq, num, den}
+}
+
+// Tempos returns the tempo changes in order.
+func (m *Map) Tempos() []Tempo { return append([]Tempo(nil), m.tempos...) }
+
+// TimeSigs returns the time signature changes in order.
+func (m *Map) TimeSigs() []TimeSig { return append([]TimeSig(nil), m.sigs...) }
+
+// TempoAt returns the tempo in BPM at ppq.
+func (m *Map) TempoAt(ppq float64) float64 {
+	i := sort.Search(len(m.tempos), func(i int) bool { return m.tempos[i].PPQ > ppq })
+	return m.tempos[max(i-1, 0)].BPM
+}
+
+// TimeSigAt returns the time signature at ppq and the position of the bar
+// ppq falls in.
+func (m *Map) TimeSigAt(ppq float64) (sig TimeSig, barStart float64) {
+	i := sort.Search(len(m.sigs), func(i int) bool { return m.sigs[i].PPQ > ppq })
+	sig = m.sigs[max(i-1, 0)]
+	// 1 小節の四分音符数。6/8 なら 3
+	bar := float64(sig.Num) * 4 / float64(sig.Den)
+	return sig, sig.PPQ + math.Floor((ppq-sig.PPQ)/bar)*bar
+}
+
+// Seconds converts a position in quarter notes into seconds.
+func (m *Map) Seconds(ppq float64) float64 {
+	sec := 0.0
+	for i, t := range m.tempos {
+		end := ppq
+		if i+1 < len(m.tempos) && m.tempos[i+1].PPQ < ppq {
+			end = m.tempos[i+1].PPQ
+		}
+		sec += (end - t.PPQ) * 60 / t.BPM
+		if end == ppq {
+			break
+		}
+	}
+	return sec
+}
+
+// PPQ converts seconds into a position in quarter notes.
+func (m *Map) PPQ(sec float64) float64 {
+	start := 0.0 // 現在の区間の開始時刻 (秒)
+	for i, t := range m.tempos {
+		if i+1 < len(m.tempos) {
+			next := start + (m.tempos[i+1].PPQ-t.PPQ)*60/t.BPM
+			if next <= sec {
+				start = next
+				continue
+			}
+		}
+		return t.PPQ + (sec-start)*t.BPM/60
+	}
+	return 0
+}
+
+// Samples converts a position in quarter notes into samples.
+func (m *Map) Samples(ppq, sampleRate float64) float64 {
+	return m.Seconds(ppq) * sampleRate
+}
+
+// SamplePPQ converts a position in samples into quarter notes.
+func (m *Map) SamplePPQ(samples, sampleRate float64) float64 {
+	return m.PPQ(samples / sampleRate)
+}
+
+// Ticks converts seconds into PPSF ticks, rounded to the nearest tick.
+func (m *Map) Ticks(sec float64) uint32 {
+	t := math.Round(m.PPQ(sec) * ppsf.TicksPerQuarter)
+	if t < 0 {
+		return 0
+	}
+	return uint32(t)
+}
+
+// TickSeconds converts PPSF ticks into seconds.
+func (m *Map) TickSeconds(ticks uint32) float64 {
+	return m.Seconds(float64(ticks) / ppsf.TicksPerQuarter)
+}
@@ -0,0 +1,103 @@
+package tempo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/ppsf"
+)
+
+// changing returns 120 BPM, 60 BPM from quarter 4 and 180 BPM from quarter
+// 10, with 3/4 from quarter 8.
+func changing() *Map {
+	m := New(120)
+	m.SetTempo(10, 180)
+	m.SetTempo(4, 60)
+	m.SetTimeSig(8, 3, 4)
+	return m
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestSeconds(t *testing.T) {
+	m := changing()
+	tests := []struct{ ppq, sec float64 }{
+		{0, 0},
+		{2, 1},     // 120 BPM
+		{4, 2},     // 60 BPM に変わる位置
+		{7, 5},     // 60 BPM
+		{10, 8},    // 180 BPM に変わる位置
+		{13, 9},    // 180 BPM
+		{-1, -0.5}, // 先頭より前は最初のテンポで伸ばす
+	}
+	for _, tt := range tests {
+		if got := m.Seconds(tt.ppq); !near(got, tt.sec) {
+			t.Errorf("Seconds(%v) = %v, want %v", tt.ppq, got, tt.sec)
+		}
+		if tt.ppq < 0 {
+			continue
+		}
+		if got := m.PPQ(tt.sec); !near(got, tt.ppq) {
+			t.Errorf("PPQ(%v) = %v, want %v", tt.sec, got, tt.ppq)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	m := changing()
+	for sec := 0.0; sec < 12; sec += 0.137 {
+		if got := m.Seconds(m.PPQ(sec)); !near(got, sec) {
+			t.Errorf("Seconds(PPQ(%v)) = %v", sec, got)
+		}
+		if got := m.Samples(m.SamplePPQ(sec*48000, 48000), 48000); !near(got, sec*48000) {
+			t.Errorf("Samples(SamplePPQ(%v)) = %v", sec*48000, got)
+		}
+	}
+	for ticks := uint32(0); ticks < 14*ppsf.TicksPerQuarter; ticks += 97 {
+		if got := m.Ticks(m.TickSeconds(ticks)); got != ticks {
+			t.Errorf("Ticks(TickSeconds(%d)) = %d", ticks, got)
+		}
+	}
+	if got := m.Ticks(-1); got != 0 {
+		t.Errorf("Ticks(-1) = %d, want 0", got)
+	}
+}
+
+func TestTempoAt(t *testing.T) {
+	m := changing()
+	m.SetTempo(4, 90) // 同じ位置は置き換える
+	tests := []struct{ ppq, bpm float64 }{{0, 120}, {3.9, 120}, {4, 90}, {9, 90}, {10, 180}, {100, 180}}
+	for _, tt := range tests {
+		if got := m.TempoAt(tt.ppq); got != tt.bpm {
+			t.Errorf("TempoAt(%v) = %v, want %v", tt.ppq, got, tt.bpm)
+		}
+	}
+	if n := len(m.Tempos()); n != 3 {
+		t.Errorf("%d tempo changes, want 3", n)
+	}
+}
+
+func TestTimeSigAt(t *testing.T) {
+	m := changing()
+	m.SetTimeSig(14, 6, 8)
+	tests := []struct {
+		ppq      float64
+		num, den int
+		barStart float64
+	}{
+		{0, 4, 4, 0},
+		{5, 4, 4, 4},
+		{7.9, 4, 4, 4},
+		{8, 3, 4, 8},
+		{12.5, 3, 4, 11},
+		{14, 6, 8, 14},
+		{18, 6, 8, 17}, // 6/8 は 1 小節 3 拍
+	}
+	for _, tt := range tests {
+		sig, bar := m.TimeSigAt(tt.ppq)
+		if sig.Num != tt.num || sig.Den != tt.den || bar != tt.barStart {
+			t.Errorf("TimeSigAt(%v) = %d/%d from %v, want %d/%d from %v",
+				tt.ppq, sig.Num, sig.Den, bar, tt.num, tt.den, tt.barStart)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/convert"
+	"github.com/isanan39s/PiaproStudio_TTS.git/tempo"
+	"pipelined.dev/audio/vst2"
+)
+
+// transport is the host sequencer position the plugin reads through
+// HostGetTime. The render loop advances it after every block.
+type transport struct {
+	mu         sync.Mutex
+	sampleRate float64
+	tempoMap   *tempo.Map
+	samplePos  float64
+	playing    bool
+	recording  bool
+	changed    bool
+
+	// info is handed to the plugin, which may keep reading it after the
+	// callback has returned, so it is pinned and reused.
+	info   *vst2.TimeInfo
+	pinner runtime.Pinner
+}
+
+// hostTransport is the transport of the plugin loaded by main. hostCallback
+// has no context argument, so it is global like the callback itself.
+var hostTransport = newTransport(renderSampleRate, tempo.New(convert.DefaultTempo))
+
+func newTransport(sampleRate float64, m *tempo.Map) *transport {
+	t := &transport{sampleRate: sampleRate, tempoMap: m, info: &vst2.TimeInfo{}}
+	t.pinner.Pin(t.info)
+	return t
+}
+
+// SetTempoMap replaces the tempo map, e.g. with the one of a generated bank.
+func (t *transport) SetTempoMap(m *tempo.Map) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tempoMap = m
+	t.changed = true
+}
+
+// Locate moves the transport to a sample position.
+func (t *transport) Locate(samplePos float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samplePos = samplePos
+	t.changed = true
+}
+
+// Play starts or stops the transport.
+func (t *transport) Play(playing bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.changed = t.changed || t.playing != playing
+	t.playing = playing
+}
+
+// Record turns record mode on or off.
+func (t *transport) Record(recording bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.changed = t.changed || t.recording != recording
+	t.recording = recording
+}
+
+// Advance moves a playing transport forward by one processed block.
+func (t *transport) Advance(frames int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.playing {
+		t.samplePos += float64(frames)
+	}
+}
+
+// timeInfo fills the pinned TimeInfo for a HostGetTime request. SamplePos,
+// SampleRate and the transport state are always set; the other fields are
+// filled, and flagged valid, only when mask asks for them.
+func (t *transport) timeInfo(mask vst2.TimeInfoFlag) *vst2.TimeInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info := t.info
+	*info = vst2.TimeInfo{SamplePos: t.samplePos, SampleRate: t.sampleRate}
+	if t.changed {
+		info.Flags |= vst2.TransportChanged
+		t.changed = false
+	}
+	if t.playing {
+		info.Flags |= vst2.TransportPlaying
+	}
+	if t.recording {
+		info.Flags |= vst2.TransportRecording
+	}
+
+	ppq := t.tempoMap.SamplePPQ(t.samplePos, t.sampleRate)
+	if mask&vst2.NanosValid != 0 {
+		info.NanoSeconds = float64(time.Now().UnixNano())
+		info.Flags |= vst2.NanosValid
+	}
+	if mask&vst2.PpqPosValid != 0 {
+		info.PpqPos = ppq
+		info.Flags |= vst2.PpqPosValid
+	}
+	if mask&vst2.TempoValid != 0 {
+		info.Tempo = t.tempoMap.TempoAt(ppq)
+		info.Flags |= vst2.TempoValid
+	}
+	sig, barStart := t.tempoMap.TimeSigAt(ppq)
+	if mask&vst2.BarsValid != 0 {
+		info.BarStartPos = barStart
+		info.Flags |= vst2.BarsValid
+	}
+	if mask&vst2.TimeSigValid != 0 {
+		info.TimeSigNumerator = int32(sig.Num)
+		info.TimeSigDenominator = int32(sig.Den)
+		info.Flags |= vst2.TimeSigValid
+	}
+	if mask&vst2.ClockValid != 0 {
+		// MIDI クロックは四分音符あたり 24
+		next := math.Ceil(ppq*24) / 24
+		info.SamplesToNextClock = int32(math.Round(t.tempoMap.Samples(next, t.sampleRate) - t.samplePos))
+		info.Flags |= vst2.ClockValid
+	}
+	return info
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"unsafe"
+
+	"github.com/isanan39s/PiaproStudio_TTS.git/convert"
+	"github.com/isanan39s/PiaproStudio_TTS.git/tempo"
+	"pipelined.dev/audio/vst2"
+)
+
+// getTime asks hostCallback for the time info like a plugin does.
+func getTime(t *testing.T, mask vst2.TimeInfoFlag) vst2.TimeInfo {
+	t.Helper()
+	ret := hostCallback(vst2.HostGetTime, 0, int64(mask), nil, 0)
+	if ret != int64(uintptr(unsafe.Pointer(hostTransport.info))) {
+		t.Fatal("HostGetTime did not return the pinned time info")
+	}
+	return *hostTransport.info
+}
+
+// useTransport sets hostTransport up with m, stopped at 0, and resets it
+// when the test ends.
+func useTransport(t *testing.T, m *tempo.Map) {
+	reset := func(m *tempo.Map) {
+		hostTransport.Play(false)
+		hostTransport.Record(false)
+		hostTransport.Locate(0)
+		hostTransport.SetTempoMap(m)
+	}
+	reset(m)
+	t.Cleanup(func() { reset(tempo.New(convert.DefaultTempo)) })
+}
+
+func TestTransportFlags(t *testing.T) {
+	useTransport(t, tempo.New(120))
+
+	info := getTime(t, 0)
+	if info.Flags != vst2.TransportChanged {
+		t.Errorf("flags = %v, want only TransportChanged", info.Flags)
+	}
+	if info := getTime(t, 0); info.Flags != 0 {
+		t.Errorf("flags = %v, want TransportChanged reported once", info.Flags)
+	}
+
+	hostTransport.Play(true)
+	hostTransport.Record(true)
+	info = getTime(t, 0)
+	want := vst2.TransportChanged | vst2.TransportPlaying | vst2.TransportRecording
+	if info.Flags != want {
+		t.Errorf("flags = %v, want %v", info.Flags, want)
+	}
+	hostTransport.Play(true) // 状態が変わらなければ Changed は立たない
+	if info := getTime(t, 0); info.Flags&vst2.TransportChanged != 0 {
+		t.Error("TransportChanged set without a change")
+	}
+	if info.SampleRate != renderSampleRate || info.SamplePos != 0 {
+		t.Errorf("sample rate %v at %v, want %v at 0", info.SampleRate, info.SamplePos, float64(renderSampleRate))
+	}
+
+	// mask で頼まれたものだけ埋める
+	all := vst2.NanosValid | vst2.PpqPosValid | vst2.TempoValid | vst2.BarsValid | vst2.TimeSigValid | vst2.ClockValid
+	for _, mask := range []vst2.TimeInfoFlag{vst2.PpqPosValid, vst2.TempoValid | vst2.TimeSigValid, all} {
+		info := getTime(t, mask)
+		if got := info.Flags & all; got != mask {
+			t.Errorf("mask %v: valid flags %v", mask, got)
+		}
+		if mask&vst2.TempoValid == 0 && info.Tempo != 0 {
+			t.Errorf("mask %v: tempo %v filled in", mask, info.Tempo)
+		}
+	}
+}
+
+func TestTransportAdvance(t *testing.T) {
+	m := tempo.New(120)
+	m.SetTempo(4, 60)
+	m.SetTimeSig(4, 3, 4)
+	useTransport(t, m)
+	mask := vst2.PpqPosValid | vst2.TempoValid | vst2.BarsValid | vst2.TimeSigValid | vst2.ClockValid
+
+	// 止まっている間は進まない
+	hostTransport.Advance(renderSampleRate)
+	if info := getTime(t, mask); info.SamplePos != 0 || info.PpqPos != 0 {
+		t.Errorf("stopped transport moved to %v (ppq %v)", info.SamplePos, info.PpqPos)
+	}
+
+	hostTransport.Play(true)
+	tests := []struct {
+		advance     int
+		samplePos   float64
+		ppq, tempo  float64
+		num, den    int32
+		barStart    float64
+		toNextClock int32
+	}{
+		{renderSampleRate, 48000, 2, 120, 4, 4, 0, 0},           // 120 BPM で 1 秒
+		{100, 48100, 2 + 100.0/24000, 120, 4, 4, 0, 1000 - 100}, // 次のクロックは 1/24 拍 = 1000 サンプル先
+		{2*renderSampleRate - 100, 144000, 5, 60, 3, 4, 4, 0},   // 4 拍目からは 60 BPM
+		{3 * renderSampleRate, 288000, 8, 60, 3, 4, 7, 0},       // 3/4 の 2 小節目
+	}
+	for _, tt := range tests {
+		hostTransport.Advance(tt.advance)
+		info := getTime(t, mask)
+		if info.SamplePos != tt.samplePos || math.Abs(info.PpqPos-tt.ppq) > 1e-9 || info.Tempo != tt.tempo {
+			t.Errorf("at %v: sample %v ppq %v tempo %v, want ppq %v tempo %v",
+				tt.samplePos, info.SamplePos, info.PpqPos, info.Tempo, tt.ppq, tt.tempo)
+		}
+		if info.TimeSigNumerator != tt.num || info.TimeSigDenominator != tt.den || info.BarStartPos != tt.barStart {
+			t.Errorf("at %v: %d/%d bar from %v, want %d/%d from %v", tt.samplePos,
+				info.TimeSigNumerator, info.TimeSigDenominator, info.BarStartPos, tt.num, tt.den, tt.barStart)
+		}
+		if info.SamplesToNextClock != tt.toNextClock {
+			t.Errorf("at %v: next clock in %d samples, want %d", tt.samplePos, info.SamplesToNextClock, tt.toNextClock)
+		}
+	}
+
+	hostTransport.Locate(0)
+	if info := getTime(t, mask); info.Flags&vst2.TransportChanged == 0 || info.PpqPos != 0 {
+		t.Errorf("after Locate: flags %v ppq %v", info.Flags, info.PpqPos)
+	}
+}
@@ -0,0 +1,190 @@
+package voicevox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultURL is where a locally started engine listens.
+const DefaultURL = "http://localhost:50021"
+
+// Client talks to a VOICEVOX engine. The zero value is not usable; create
+// one with NewClient.
+type Client struct {
+	// BaseURL is the engine address without a trailing slash, e.g.
+	// DefaultURL.
+	BaseURL string
+	// HTTPClient sends the requests. nil means http.DefaultClient.
+	HTTPClient *http.Client
+	// Policy sets timeouts, retries and the circuit breaker. nil means
+	// DefaultPolicy.
+	Policy *Policy
+
+	breaker breaker
+}
+
+// NewClient returns a client for the engine at baseURL. An empty baseURL
+// means DefaultURL.
+func NewClient(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// QueryOptions are the optional parameters of audio_query and
+// accent_phrases.
+type QueryOptions struct {
+	// NoKatakanaEnglish stops the engine from reading unknown English words
+	// in katakana.
+	NoKatakanaEnglish bool
+	// CoreVersion selects the engine core. Empty means the default one.
+	CoreVersion string
+}
+
+func (o QueryOptions) values(v url.Values) {
+	v.Set("enable_katakana_english", strconv.FormatBool(!o.NoKatakanaEnglish))
+	if o.CoreVersion != "" {
+		v.Set("core_version", o.CoreVersion)
+	}
+}
+
+// AudioQuery runs text analysis on text and returns the query the engine
+// would synthesize with the given style.
+func (c *Client) AudioQuery(ctx context.Context, text string, speaker int, opt QueryOptions) (*ResponseData, error) {
+	v := speakerValues(speaker)
+	v.Set("text", text)
+	opt.values(v)
+	var q ResponseData
+	if err := c.do(ctx, http.MethodPost, "/audio_query", v, nil, &q); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// AccentPhrases returns the accent phrases of text with lengths and pitch
+// filled in.
+func (c *Client) AccentPhrases(ctx context.Context, text string, speaker int, opt QueryOptions) ([]AccentPhrase, error) {
+	v := speakerValues(speaker)
+	v.Set("text", text)
+	opt.values(v)
+	var phrases []AccentPhrase
+	if err := c.do(ctx, http.MethodPost, "/accent_phrases", v, nil, &phrases); err != nil {
+		return nil, err
+	}
+	return phrases, nil
+}
+
+// MoraData recomputes both the lengths and the pitch of phrases, e.g. after
+// the accent positions were edited.
+func (c *Client) MoraData(ctx context.Context, phrases []AccentPhrase, speaker int) ([]AccentPhrase, error) {
+	return c.updatePhrases(ctx, "/mora_data", phrases, speaker)
+}
+
+// MoraPitch recomputes the pitch of phrases and keeps their lengths.
+func (c *Client) MoraPitch(ctx context.Context, phrases []AccentPhrase, speaker int) ([]AccentPhrase, error) {
+	return c.updatePhrases(ctx, "/mora_pitch", phrases, speaker)
+}
+
+// MoraLength recomputes the lengths of phrases and keeps their pitch.
+func (c *Client) MoraLength(ctx context.Context, phrases []AccentPhrase, speaker int) ([]AccentPhrase, error) {
+	return c.updatePhrases(ctx, "/mora_length", phrases, speaker)
+}
+
+func (c *Client) updatePhrases(ctx context.Context, path string, phrases []AccentPhrase, speaker int) ([]AccentPhrase, error) {
+	var out []AccentPhrase
+	if err := c.do(ctx, http.MethodPost, path, speakerValues(speaker), phrases, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Speakers lists the characters the engine can speak with and their styles.
+func (c *Client) Speakers(ctx context.Context) ([]Speaker, error) {
+	var speakers []Speaker
+	if err := c.do(ctx, http.MethodGet, "/speakers", nil, nil, &speakers); err != nil {
+		return nil, err
+	}
+	return speakers, nil
+}
+
+func speakerValues(speaker int) url.Values {
+	return url.Values{"speaker": {strconv.Itoa(speaker)}}
+}
+
+// do sends one request under the retry policy of c. body, when not nil, is
+// sent as JSON and the JSON response is decoded into out.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	return c.request(ctx, c.policy(), method, path, query, body, out)
+}
+
+// doOnce is do for requests that must not be repeated: after a timeout the
+// engine may already have carried out the first attempt, e.g. added a word.
+func (c *Client) doOnce(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	p := c.policy()
+	p.Retries = 0
+	return c.request(ctx, p, method, path, query, body, out)
+}
+
+func (c *Client) request(ctx context.Context, p Policy, method, path string, query url.Values, body, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("voicevox: %s: %w", path, err)
+		}
+	}
+	return c.retry(ctx, p, func(ctx context.Context) error {
+		return c.send(ctx, method, path, query, data, out)
+	})
+}
+
+// send makes a single attempt of a request.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body []byte, out any) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return fmt.Errorf("voicevox: %s: %w", path, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("voicevox: %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("voicevox: %s: %w", path, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return newError(method, path, resp, data)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("voicevox: %s: bad response: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package voicevox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// recordedQuery is testdata/audio_query.json, the /audio_query answer of
+// vvengin_reply.txt for "テキスト？" without its notes.
+func recordedQuery(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile("../testdata/audio_query.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+const speakersJSON = `[{"name": "ずんだもん", "speaker_uuid": "388f246b-8c41-4ac1-8e2d-5d79f3ff56d9",
+	"styles": [{"name": "ノーマル", "id": 3, "type": "talk"}, {"name": "あまあま", "id": 1, "type": "talk"}],
+	"version": "0.14.0"}]`
+
+// newEngine starts a stand-in engine serving mux. The client does not retry
+// unless the test sets a policy.
+func newEngine(t *testing.T, mux *http.ServeMux) *Client {
+	t.Helper()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	c := NewClient(srv.URL)
+	c.Policy = &Policy{}
+	return c
+}
+
+func TestAudioQuery(t *testing.T) {
+	reply := recordedQuery(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /audio_query", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("text") != "テキスト?" || q.Get("speaker") != "1" || q.Get("enable_katakana_english") != "true" {
+			t.Errorf("query %v", q)
+		}
+		w.Write([]byte(reply))
+	})
+	c := newEngine(t, mux)
+
+	q, err := c.AudioQuery(context.Background(), "テキスト?", 1, QueryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.AccentPhrases) != 1 || len(q.AccentPhrases[0].Moras) != 4 {
+		t.Fatalf("got %+v", q.AccentPhrases)
+	}
+	p := q.AccentPhrases[0]
+	if !p.IsInterrogative || p.Accent != 1 || p.PauseMora != nil {
+		t.Errorf("phrase %+v", p)
+	}
+	if m := p.Moras[1]; m.Text != "キ" || m.Voiced() {
+		t.Errorf("キ should be devoiced: %+v", m)
+	}
+	if q.Kana != "テ'_キスト？" || q.PrePhonemeLength != 0.1 || q.PauseLength != nil {
+		t.Errorf("query %+v", q)
+	}
+}
+
+func TestSpeakers(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /speakers", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(speakersJSON))
+	})
+	c := newEngine(t, mux)
+
+	speakers, err := c.Speakers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(speakers) != 1 || speakers[0].Name != "ずんだもん" || len(speakers[0].Styles) != 2 {
+		t.Fatalf("got %+v", speakers)
+	}
+	if st := speakers[0].Styles[1]; st.ID != 1 || st.Name != "あまあま" || st.Type != StyleTalk {
+		t.Errorf("style %+v", st)
+	}
+}
+
+func TestErrorDetail(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /audio_query", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"detail": [{"loc": ["query", "speaker"], "msg": "field required", "type": "value_error.missing"}]}`))
+	})
+	mux.HandleFunc("DELETE /user_dict_word/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"detail": "単語が見つかりませんでした"}`))
+	})
+	c := newEngine(t, mux)
+	ctx := context.Background()
+
+	_, err := c.AudioQuery(ctx, "a", 1, QueryOptions{})
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("got %v, want *Error", err)
+	}
+	if e.StatusCode != 422 || len(e.Validation) != 1 || e.Detail != "" {
+		t.Fatalf("got %+v", e)
+	}
+	if v := e.Validation[0]; v.String() != "query.speaker: field required" || v.Type != "value_error.missing" {
+		t.Errorf("validation %+v", v)
+	}
+
+	err = c.DeleteWord(ctx, "x")
+	if !errors.As(err, &e) {
+		t.Fatalf("got %v, want *Error", err)
+	}
+	if e.StatusCode != 404 || e.Detail != "単語が見つかりませんでした" || e.Validation != nil {
+		t.Errorf("got %+v", e)
+	}
+}
@@ -0,0 +1,77 @@
+package voicevox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error is returned when the engine answers with a status other than 2xx.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Detail is the "detail" message of the response when it is a plain
+	// string, e.g. for 400 and 404.
+	Detail string
+	// Validation holds the reasons of a 422 Unprocessable Entity.
+	Validation []ValidationError
+	// Body is the raw response body.
+	Body []byte
+}
+
+// ValidationError is one entry of the "detail" list the engine returns with
+// 422, e.g. {"loc": ["query", "speaker"], "msg": "field required"}.
+type ValidationError struct {
+	// Loc is the path to the bad parameter. Entries are strings, or numbers
+	// for list indices.
+	Loc  []any  `json:"loc"`
+	Msg  string `json:"msg"`
+	Type string `json:"type"`
+}
+
+func (e ValidationError) String() string {
+	loc := make([]string, len(e.Loc))
+	for i, l := range e.Loc {
+		loc[i] = fmt.Sprint(l)
+	}
+	return strings.Join(loc, ".") + ": " + e.Msg
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("voicevox: %s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	switch {
+	case len(e.Validation) > 0:
+		reasons := make([]string, len(e.Validation))
+		for i, v := range e.Validation {
+			reasons[i] = v.String()
+		}
+		msg += ": " + strings.Join(reasons, "; ")
+	case e.Detail != "":
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+func newError(method, path string, resp *http.Response, body []byte) *Error {
+	e := &Error{Method: method, Path: path, StatusCode: resp.StatusCode, Body: body}
+	var r struct {
+		Detail json.RawMessage `json:"detail"`
+	}
+	// detail は 422 ならリスト、読み仮名の構文エラーなら {"text": ...}、
+	// それ以外は文字列で返ってくる
+	if json.Unmarshal(body, &r) != nil || len(r.Detail) == 0 {
+		return e
+	}
+	if json.Unmarshal(r.Detail, &e.Validation) == nil || json.Unmarshal(r.Detail, &e.Detail) == nil {
+		return e
+	}
+	var kana struct {
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(r.Detail, &kana) == nil {
+		e.Detail = kana.Text
+	}
+	return e
+}
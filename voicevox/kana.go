@@ -0,0 +1,175 @@
+package voicevox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The marks of the AquesTalk-like kana the engine returns in
+// ResponseData.Kana and reads back with is_kana, e.g. "テ'_キスト".
+const (
+	kanaAccent        = '\''
+	kanaDevoice       = '_'
+	kanaNoPause       = '/'
+	kanaPause         = '、'
+	kanaInterrogative = '？'
+)
+
+// KanaPhrase is one accent phrase of the kana notation.
+type KanaPhrase struct {
+	Moras []KanaMora
+	// Accent is the 1-based position of the mora marked with "'", after
+	// which the pitch falls.
+	Accent int
+	// Interrogative is set by a trailing "？".
+	Interrogative bool
+	// Pause is set when the phrase ends with "、" instead of "/".
+	Pause bool
+}
+
+// KanaMora is one mora of a KanaPhrase: a katakana letter, with the small
+// letter that follows it if any.
+type KanaMora struct {
+	Text string
+	// Devoiced is set by a "_" in front of the mora.
+	Devoiced bool
+}
+
+// ParseKana parses the kana notation: katakana moras, "'" after the
+// accented mora (exactly one per phrase), "_" before a devoiced mora, "？"
+// at the end of an interrogative phrase and "/" or "、" between phrases,
+// the latter with a pause.
+func ParseKana(s string) ([]KanaPhrase, error) {
+	var phrases []KanaPhrase
+	var p KanaPhrase
+	devoice := false
+	end := func(pause bool) error {
+		switch {
+		case len(p.Moras) == 0:
+			return fmt.Errorf("voicevox: kana %q: empty accent phrase %d", s, len(phrases)+1)
+		case devoice:
+			return fmt.Errorf("voicevox: kana %q: %q without a mora", s, kanaDevoice)
+		case p.Accent == 0:
+			return fmt.Errorf("voicevox: kana %q: accent phrase %d has no accent", s, len(phrases)+1)
+		}
+		p.Pause = pause
+		phrases = append(phrases, p)
+		p = KanaPhrase{}
+		return nil
+	}
+
+	for _, c := range s {
+		if p.Interrogative && c != kanaNoPause && c != kanaPause {
+			return nil, fmt.Errorf("voicevox: kana %q: %q must end a phrase", s, kanaInterrogative)
+		}
+		switch {
+		case c == kanaAccent:
+			if len(p.Moras) == 0 || p.Accent != 0 {
+				return nil, fmt.Errorf("voicevox: kana %q: misplaced %q in accent phrase %d", s, kanaAccent, len(phrases)+1)
+			}
+			p.Accent = len(p.Moras)
+		case c == kanaDevoice:
+			devoice = true
+		case c == kanaInterrogative:
+			p.Interrogative = true
+		case c == kanaNoPause || c == kanaPause:
+			if err := end(c == kanaPause); err != nil {
+				return nil, err
+			}
+		case isSmallKana(c) && len(p.Moras) > 0 && !devoice:
+			p.Moras[len(p.Moras)-1].Text += string(c)
+		case c >= 'ァ' && c <= 'ヶ' || c == 'ー':
+			p.Moras = append(p.Moras, KanaMora{Text: string(c), Devoiced: devoice})
+			devoice = false
+		default:
+			return nil, fmt.Errorf("voicevox: kana %q: unexpected %q", s, c)
+		}
+	}
+	if err := end(false); err != nil {
+		return nil, err
+	}
+	return phrases, nil
+}
+
+// isSmallKana reports whether c is a small letter that joins the mora in
+// front of it, as in "キャ". The small ッ is a mora of its own.
+func isSmallKana(c rune) bool {
+	return strings.ContainsRune("ァィゥェォャュョヮ", c)
+}
+
+// FormatKana is the inverse of ParseKana.
+func FormatKana(phrases []KanaPhrase) string {
+	var b strings.Builder
+	for i, p := range phrases {
+		for j, m := range p.Moras {
+			if m.Devoiced {
+				b.WriteRune(kanaDevoice)
+			}
+			b.WriteString(m.Text)
+			if j+1 == p.Accent {
+				b.WriteRune(kanaAccent)
+			}
+		}
+		if p.Interrogative {
+			b.WriteRune(kanaInterrogative)
+		}
+		if i+1 < len(phrases) {
+			if p.Pause {
+				b.WriteRune(kanaPause)
+			} else {
+				b.WriteRune(kanaNoPause)
+			}
+		}
+	}
+	return b.String()
+}
+
+// Kana returns the accent phrases in the kana notation, e.g. to edit the
+// accents of an audio query by hand.
+func Kana(phrases []AccentPhrase) []KanaPhrase {
+	out := make([]KanaPhrase, len(phrases))
+	for i, p := range phrases {
+		k := KanaPhrase{Accent: p.Accent, Interrogative: p.IsInterrogative, Pause: p.PauseMora != nil}
+		for _, m := range p.Moras {
+			// 無声化した母音は大文字で返ってくる
+			k.Moras = append(k.Moras, KanaMora{Text: m.Text, Devoiced: strings.ContainsAny(m.Vowel, "AIUEO")})
+		}
+		out[i] = k
+	}
+	return out
+}
+
+// AccentPhrasesFromKana is AccentPhrases for kana notation: the engine
+// skips its text analysis and takes the accents and devoicing as written.
+// kana is checked with ParseKana before it is sent.
+func (c *Client) AccentPhrasesFromKana(ctx context.Context, kana string, speaker int) ([]AccentPhrase, error) {
+	if _, err := ParseKana(kana); err != nil {
+		return nil, err
+	}
+	v := speakerValues(speaker)
+	v.Set("text", kana)
+	v.Set("is_kana", "true")
+	var phrases []AccentPhrase
+	if err := c.do(ctx, http.MethodPost, "/accent_phrases", v, nil, &phrases); err != nil {
+		return nil, err
+	}
+	return phrases, nil
+}
+
+// AudioQueryFromKana builds an audio query from kana notation with the
+// engine's default scales, like audio_query does for text.
+func (c *Client) AudioQueryFromKana(ctx context.Context, kana string, speaker int) (*ResponseData, error) {
+	phrases, err := c.AccentPhrasesFromKana(ctx, kana, speaker)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseData{
+		AccentPhrases:     phrases,
+		SpeedScale:        1,
+		PrePhonemeLength:  0.1,
+		PostPhonemeLength: 0.1,
+		Kana:              kana,
+	}, nil
+}
@@ -0,0 +1,84 @@
+package voicevox
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseKana(t *testing.T) {
+	tests := []struct {
+		kana string
+		want []KanaPhrase
+	}{
+		{"テ'_キスト？", []KanaPhrase{{
+			Moras:         []KanaMora{{Text: "テ"}, {Text: "キ", Devoiced: true}, {Text: "ス"}, {Text: "ト"}},
+			Accent:        1,
+			Interrogative: true,
+		}}},
+		{"コンニチワ'/ミ'クダヨ", []KanaPhrase{
+			{Moras: []KanaMora{{Text: "コ"}, {Text: "ン"}, {Text: "ニ"}, {Text: "チ"}, {Text: "ワ"}}, Accent: 5},
+			{Moras: []KanaMora{{Text: "ミ"}, {Text: "ク"}, {Text: "ダ"}, {Text: "ヨ"}}, Accent: 1},
+		}},
+		{"コンニチワ'、ミ'クダヨ", []KanaPhrase{
+			{Moras: []KanaMora{{Text: "コ"}, {Text: "ン"}, {Text: "ニ"}, {Text: "チ"}, {Text: "ワ"}}, Accent: 5, Pause: true},
+			{Moras: []KanaMora{{Text: "ミ"}, {Text: "ク"}, {Text: "ダ"}, {Text: "ヨ"}}, Accent: 1},
+		}},
+		// 小書きは前のモーラに付くが、ッ はそれだけで 1 モーラ
+		{"キャ'ット", []KanaPhrase{{Moras: []KanaMora{{Text: "キャ"}, {Text: "ッ"}, {Text: "ト"}}, Accent: 1}}},
+		{"_シュ'ーズ？/ホ'ント", []KanaPhrase{
+			{Moras: []KanaMora{{Text: "シュ", Devoiced: true}, {Text: "ー"}, {Text: "ズ"}}, Accent: 1, Interrogative: true},
+			{Moras: []KanaMora{{Text: "ホ"}, {Text: "ン"}, {Text: "ト"}}, Accent: 1},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := ParseKana(tt.kana)
+		if err != nil {
+			t.Errorf("ParseKana(%q): %v", tt.kana, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseKana(%q) = %+v, want %+v", tt.kana, got, tt.want)
+		}
+		if s := FormatKana(got); s != tt.kana {
+			t.Errorf("FormatKana(ParseKana(%q)) = %q", tt.kana, s)
+		}
+	}
+}
+
+func TestParseKanaErrors(t *testing.T) {
+	for _, kana := range []string{
+		"",
+		"テキスト",   // アクセントがない
+		"テ'キ/スト", // 2 つ目の句にアクセントがない
+		"'テキスト",  // モーラより前のアクセント
+		"テ'キ'スト", // アクセントが 2 つ
+		"テ'？キスト", // 句の途中の ？
+		"テ'キスト_", // 後ろにモーラがない _
+		"テ'_/ス'ト",
+		"テ'//ス'ト", // 空の句
+		"テ'キスト/",
+		"テ'kisuto",
+	} {
+		if got, err := ParseKana(kana); err == nil {
+			t.Errorf("ParseKana(%q) = %+v, want an error", kana, got)
+		}
+	}
+}
+
+func TestKana(t *testing.T) {
+	var q ResponseData
+	if err := json.Unmarshal([]byte(recordedQuery(t)), &q); err != nil {
+		t.Fatal(err)
+	}
+	if got := FormatKana(Kana(q.AccentPhrases)); got != q.Kana {
+		t.Errorf("Kana = %q, want the engine's %q", got, q.Kana)
+	}
+	// 読点のある句は、で区切る
+	phrases := append([]AccentPhrase(nil), q.AccentPhrases...)
+	phrases[0].PauseMora = &Mora{Text: "、", Vowel: "pau"}
+	phrases = append(phrases, q.AccentPhrases[0])
+	if got, want := FormatKana(Kana(phrases)), "テ'_キスト？、テ'_キスト？"; got != want {
+		t.Errorf("Kana = %q, want %q", got, want)
+	}
+}
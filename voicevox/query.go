@@ -0,0 +1,53 @@
+// Package voicevox is a client for the VOICEVOX engine HTTP API and holds its
+// types.
+package voicevox
+
+// Mora は各音素の情報を保持します
+type Mora struct {
+	Text            string  `json:"text"`             /// 文字
+	Consonant       string  `json:"consonant"`        /// 子音発音 (無い時は空)
+	ConsonantLength float64 `json:"consonant_length"` /// 子音長さ (秒)
+	Vowel           string  `json:"vowel"`            /// 母音発音
+	VowelLength     float64 `json:"vowel_length"`     /// 母音長さ (秒)
+	Pitch           float64 `json:"pitch"`            /// log(Hz)、無声化なら 0
+}
+
+// Voiced reports whether the mora has a pitch. Devoiced moras such as the
+// "キ" of "テキスト" come back with pitch 0.
+func (m Mora) Voiced() bool { return m.Pitch > 0 }
+
+// AccentPhrase はアクセント句の情報を保持します
+type AccentPhrase struct {
+	Moras           []Mora `json:"moras"`            /// 各音素
+	Accent          int    `json:"accent"`           /// アクセント位置
+	PauseMora       *Mora  `json:"pause_mora"`       /// アクセント句の末尾につく無音モーラ (句読点)
+	IsInterrogative bool   `json:"is_interrogative"` /// ？で終わる句。true なら語尾を上げる
+}
+
+// ResponseData は audio_query のレスポンス全体を保持します
+type ResponseData struct {
+	AccentPhrases     []AccentPhrase `json:"accent_phrases"`
+	SpeedScale        float64        `json:"speedScale"`
+	PitchScale        float64        `json:"pitchScale"`
+	IntonationScale   *float64       `json:"intonationScale"`   /// 抑揚（倍率）、null なら 1
+	VolumeScale       *float64       `json:"volumeScale"`       /// 音量（倍率）、null なら 1。0 は無音
+	PrePhonemeLength  float64        `json:"prePhonemeLength"`  /// 音声の前の無音時間 (秒)
+	PostPhonemeLength float64        `json:"postPhonemeLength"` /// 音声の後の無音時間 (秒)
+	PauseLength       *float64       `json:"pauseLength"`       /// 句読点などの無音時間、null ならモーラの長さ
+	PauseLengthScale  *float64       `json:"pauseLengthScale"`  /// 句読点などの無音時間（倍率）
+	Kana              string         `json:"kana"`
+}
+
+// Pause returns the silence the engine inserts for a pause mora: the mora
+// length, or pauseLength when set, times pauseLengthScale.
+func (q *ResponseData) Pause(m Mora) float64 {
+	length := m.VowelLength
+	if q.PauseLength != nil {
+		length = *q.PauseLength
+	}
+	// 古いエンジンは pauseLengthScale を返さない
+	if q.PauseLengthScale != nil {
+		length *= *q.PauseLengthScale
+	}
+	return length
+}
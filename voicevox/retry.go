@@ -0,0 +1,189 @@
+package voicevox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrUnavailable is returned without contacting the engine while the
+// circuit breaker is open, i.e. after the engine failed several times in a
+// row.
+var ErrUnavailable = errors.New("voicevox: engine unavailable")
+
+// Policy controls how the client copes with a slow or restarting engine.
+// Zero fields disable the feature they control.
+type Policy struct {
+	// Timeout bounds each attempt, on top of the context of the call.
+	Timeout time.Duration
+	// Retries is how many times a request is repeated after a connection
+	// failure, a timeout or a 5xx answer.
+	Retries int
+	// Backoff is the wait before the first retry. It doubles for every
+	// further retry, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// BreakerThreshold is the number of failed attempts in a row after
+	// which requests fail fast with ErrUnavailable.
+	BreakerThreshold int
+	// BreakerCooldown is how long the breaker stays open. The next request
+	// after it is let through; its result closes or reopens the breaker.
+	BreakerCooldown time.Duration
+}
+
+// DefaultPolicy is used by clients whose Policy is nil. The engine may take
+// several seconds to load its models after a start.
+var DefaultPolicy = Policy{
+	Timeout:          30 * time.Second,
+	Retries:          3,
+	Backoff:          250 * time.Millisecond,
+	MaxBackoff:       5 * time.Second,
+	BreakerThreshold: 5,
+	BreakerCooldown:  10 * time.Second,
+}
+
+func (c *Client) policy() Policy {
+	if c.Policy == nil {
+		return DefaultPolicy
+	}
+	return *c.Policy
+}
+
+// backoff returns the wait before retry n, n starting at 0.
+func (p Policy) backoff(n int) time.Duration {
+	d := p.Backoff
+	for i := 0; i < n && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// retry calls attempt until it succeeds, fails for a reason retrying does
+// not fix, or p gives up.
+func (c *Client) retry(ctx context.Context, p Policy, attempt func(ctx context.Context) error) error {
+	if err := c.breaker.allow(p); err != nil {
+		return err
+	}
+	for n := 0; ; n++ {
+		err := c.try(ctx, p, attempt)
+		failed := err != nil && retryable(ctx, err)
+		c.breaker.record(p, failed)
+		if !failed || n >= p.Retries {
+			return err
+		}
+		if c.breaker.allow(p) != nil {
+			return err
+		}
+		select {
+		case <-time.After(p.backoff(n)):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func (c *Client) try(ctx context.Context, p Policy, attempt func(ctx context.Context) error) error {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	return attempt(ctx)
+}
+
+// retryable reports whether err may go away by itself: the engine is not
+// listening (yet), dropped the connection, timed out or failed with 5xx.
+// Nothing is retried once the caller's ctx is done.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode >= 500
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// breaker is a circuit breaker counting failed attempts in a row.
+type breaker struct {
+	mu       sync.Mutex
+	failures int
+	openTill time.Time
+}
+
+func (b *breaker) allow(p Policy) error {
+	if p.BreakerThreshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < p.BreakerThreshold || !time.Now().Before(b.openTill) {
+		return nil
+	}
+	return fmt.Errorf("%w for %s after %d failures", ErrUnavailable, time.Until(b.openTill).Round(time.Millisecond), b.failures)
+}
+
+func (b *breaker) record(p Policy, failed bool) {
+	if p.BreakerThreshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= p.BreakerThreshold {
+		// 半開状態で再び失敗した場合もここで開き直す
+		b.openTill = time.Now().Add(p.BreakerCooldown)
+	}
+}
+
+// Version returns the version of the engine, e.g. "0.14.0". It is a single
+// attempt that bypasses the retries and the circuit breaker, so it can be
+// used as a readiness probe.
+func (c *Client) Version(ctx context.Context) (string, error) {
+	p := c.policy()
+	var v string
+	err := c.try(ctx, p, func(ctx context.Context) error {
+		return c.send(ctx, http.MethodGet, "/version", nil, nil, &v)
+	})
+	// 応答があればエンジンは生きているのでブレーカーを閉じる
+	c.breaker.record(p, err != nil && retryable(ctx, err))
+	return v, err
+}
+
+// WaitReady polls /version every interval until the engine answers or ctx
+// is done, and returns the engine version.
+func (c *Client) WaitReady(ctx context.Context, interval time.Duration) (string, error) {
+	for {
+		v, err := c.Version(ctx)
+		if err == nil {
+			return v, nil
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return "", fmt.Errorf("voicevox: engine not ready: %w", err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package voicevox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	ms := time.Millisecond
+	for _, c := range []struct {
+		p    Policy
+		want []time.Duration
+	}{
+		{Policy{Backoff: 100 * ms}, []time.Duration{100 * ms, 200 * ms, 400 * ms, 800 * ms}},
+		{Policy{Backoff: 100 * ms, MaxBackoff: 250 * ms}, []time.Duration{100 * ms, 200 * ms, 250 * ms, 250 * ms}},
+	} {
+		for n, want := range c.want {
+			if got := c.p.backoff(n); got != want {
+				t.Errorf("%+v: backoff(%d) = %v, want %v", c.p, n, got, want)
+			}
+		}
+	}
+}
+
+// failing returns a handler that answers status for the first n requests
+// and then ok, counting the requests in hits.
+func failing(n int64, status int, ok string, hits *atomic.Int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= n {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"detail": "busy"}`))
+			return
+		}
+		w.Write([]byte(ok))
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var hits atomic.Int64
+	mux := http.NewServeMux()
+	mux.Handle("GET /speakers", failing(2, http.StatusServiceUnavailable, speakersJSON, &hits))
+	c := newEngine(t, mux)
+	c.Policy = &Policy{Retries: 3, Backoff: time.Millisecond}
+
+	if _, err := c.Speakers(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if hits.Load() != 3 {
+		t.Errorf("%d requests, want 3", hits.Load())
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var hits atomic.Int64
+	mux := http.NewServeMux()
+	mux.Handle("GET /speakers", failing(10, http.StatusInternalServerError, speakersJSON, &hits))
+	mux.Handle("GET /user_dict", failing(10, http.StatusBadRequest, "{}", &hits))
+	c := newEngine(t, mux)
+	c.Policy = &Policy{Retries: 2, Backoff: time.Millisecond}
+	ctx := context.Background()
+
+	var e *Error
+	if _, err := c.Speakers(ctx); !errors.As(err, &e) || e.StatusCode != 500 {
+		t.Fatalf("got %v", err)
+	}
+	if hits.Load() != 3 {
+		t.Errorf("%d requests for a 500, want 3", hits.Load())
+	}
+	// 4xx は再送しても直らない
+	hits.Store(0)
+	if _, err := c.UserDict(ctx); !errors.As(err, &e) || e.StatusCode != 400 {
+		t.Fatalf("got %v", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("%d requests for a 400, want 1", hits.Load())
+	}
+}
+
+func TestRetryTimeout(t *testing.T) {
+	var hits atomic.Int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /speakers", func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(speakersJSON))
+	})
+	c := newEngine(t, mux)
+	c.Policy = &Policy{Timeout: 50 * time.Millisecond, Retries: 1, Backoff: time.Millisecond}
+
+	if _, err := c.Speakers(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("%d requests, want 2", hits.Load())
+	}
+}
+
+func TestWritesNotRetried(t *testing.T) {
+	var hits atomic.Int64
+	mux := http.NewServeMux()
+	mux.Handle("POST /user_dict_word", failing(10, http.StatusServiceUnavailable, `"id"`, &hits))
+	mux.Handle("POST /import_user_dict", failing(10, http.StatusServiceUnavailable, "", &hits))
+	mux.Handle("PUT /user_dict_word/{id}", failing(10, http.StatusServiceUnavailable, "", &hits))
+	mux.Handle("DELETE /user_dict_word/{id}", failing(10, http.StatusServiceUnavailable, "", &hits))
+	c := newEngine(t, mux)
+	c.Policy = &Policy{Retries: 3, Backoff: time.Millisecond}
+	ctx := context.Background()
+
+	if _, err := c.AddWord(ctx, Word{Surface: "ミク", Pronunciation: "ミク"}); err == nil {
+		t.Fatal("AddWord succeeded")
+	}
+	if err := c.ImportUserDict(ctx, nil, false); err == nil {
+		t.Fatal("ImportUserDict succeeded")
+	}
+	if err := c.UpdateWord(ctx, "id", Word{Surface: "ミク", Pronunciation: "ミク"}); err == nil {
+		t.Fatal("UpdateWord succeeded")
+	}
+	if err := c.DeleteWord(ctx, "id"); err == nil {
+		t.Fatal("DeleteWord succeeded")
+	}
+	if hits.Load() != 4 {
+		t.Errorf("%d requests, want one per call", hits.Load())
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	var hits atomic.Int64
+	mux := http.NewServeMux()
+	mux.Handle("GET /speakers", failing(2, http.StatusServiceUnavailable, speakersJSON, &hits))
+	c := newEngine(t, mux)
+	c.Policy = &Policy{BreakerThreshold: 2, BreakerCooldown: 50 * time.Millisecond}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Speakers(ctx); err == nil || errors.Is(err, ErrUnavailable) {
+			t.Fatalf("request %d: got %v, want the 503", i, err)
+		}
+	}
+	if _, err := c.Speakers(ctx); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v, want ErrUnavailable", err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("%d requests reached the engine while the breaker was open", hits.Load()-2)
+	}
+
+	// クールダウン後の 1 回が通り、成功すればブレーカーが閉じる
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if _, err := c.Speakers(ctx); err != nil {
+			t.Fatalf("after cooldown: %v", err)
+		}
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	var hits atomic.Int64
+	mux := http.NewServeMux()
+	mux.Handle("GET /version", failing(2, http.StatusServiceUnavailable, `"0.14.0"`, &hits))
+	c := newEngine(t, mux)
+	c.Policy = &Policy{BreakerThreshold: 1, BreakerCooldown: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	v, err := c.WaitReady(ctx, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "0.14.0" || hits.Load() != 3 {
+		t.Errorf("got %q after %d polls", v, hits.Load())
+	}
+	// 準備完了でブレーカーも閉じている
+	if err := c.breaker.allow(*c.Policy); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWaitReadyGivesUp(t *testing.T) {
+	c := newEngine(t, http.NewServeMux())
+	c.BaseURL = "http://127.0.0.1:1" // 何も待ち受けていない
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.WaitReady(ctx, 5*time.Millisecond); err == nil {
+		t.Fatal("WaitReady succeeded without an engine")
+	}
+}
@@ -0,0 +1,72 @@
+package voicevox
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Speaker is one character of the /speakers list.
+type Speaker struct {
+	Name        string  `json:"name"`         /// キャラクター名 (例: ずんだもん)
+	SpeakerUUID string  `json:"speaker_uuid"` /// キャラクターの UUID
+	Styles      []Style `json:"styles"`       /// 話し方の一覧
+	Version     string  `json:"version"`      /// キャラクターのバージョン
+}
+
+// Style is one way of speaking of a Speaker. Its ID is the "speaker"
+// parameter of the other endpoints.
+type Style struct {
+	Name string `json:"name"` /// スタイル名 (例: ノーマル)
+	ID   int    `json:"id"`   /// audio_query などに渡す speaker
+	Type string `json:"type"` /// talk, singing_teacher, frame_decode, sing
+}
+
+// StyleTalk is the Style.Type of styles that can read text. Styles of other
+// types only sing and are rejected by audio_query.
+const StyleTalk = "talk"
+
+// StyleName returns the "speaker/style" name of st, e.g. "ずんだもん/ノーマル".
+func (s *Speaker) StyleName(st Style) string { return s.Name + "/" + st.Name }
+
+// FindStyle looks a style up by name. name is "speaker/style", e.g.
+// "ずんだもん/ノーマル", a bare speaker name meaning its first talk style,
+// or a style id such as "3".
+func FindStyle(speakers []Speaker, name string) (*Speaker, *Style, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		for i := range speakers {
+			for j := range speakers[i].Styles {
+				if speakers[i].Styles[j].ID == id {
+					return &speakers[i], &speakers[i].Styles[j], nil
+				}
+			}
+		}
+		return nil, nil, fmt.Errorf("voicevox: no style with id %d", id)
+	}
+
+	speaker, style, _ := strings.Cut(name, "/")
+	for i := range speakers {
+		s := &speakers[i]
+		if s.Name != speaker {
+			continue
+		}
+		for j := range s.Styles {
+			st := &s.Styles[j]
+			if style == "" && (st.Type == "" || st.Type == StyleTalk) || style != "" && st.Name == style {
+				return s, st, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("voicevox: %s has no style %q", s.Name, style)
+	}
+	return nil, nil, fmt.Errorf("voicevox: no speaker %q", speaker)
+}
+
+// FindStyle fetches /speakers and looks name up with FindStyle.
+func (c *Client) FindStyle(ctx context.Context, name string) (*Speaker, *Style, error) {
+	speakers, err := c.Speakers(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return FindStyle(speakers, name)
+}
@@ -0,0 +1,215 @@
+package voicevox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// Word types accepted by AddWord and UpdateWord.
+const (
+	ProperNoun = "PROPER_NOUN"
+	CommonNoun = "COMMON_NOUN"
+	Verb       = "VERB"
+	Adjective  = "ADJECTIVE"
+	Suffix     = "SUFFIX"
+)
+
+// partOfSpeech is how the engine stores each word type: the part of speech
+// and its first two details.
+var partOfSpeech = map[string][3]string{
+	ProperNoun: {"名詞", "固有名詞", "一般"},
+	CommonNoun: {"名詞", "一般", "*"},
+	Verb:       {"動詞", "自立", "*"},
+	Adjective:  {"形容詞", "自立", "*"},
+	Suffix:     {"名詞", "接尾", "一般"},
+}
+
+// DefaultPriority is the priority the engine gives to words added without
+// one. Priorities go from 0 to 10.
+const DefaultPriority = 5
+
+// UserDictWord is a word of the engine's user dictionary as /user_dict
+// returns it. The full record is kept so exported dictionaries can be
+// imported again unchanged.
+type UserDictWord struct {
+	Surface               string `json:"surface"`                 /// 表記 (エンジン側で全角に変換される)
+	Priority              int    `json:"priority"`                /// 優先度 0〜10
+	ContextID             int    `json:"context_id"`              /// 文脈 ID
+	PartOfSpeech          string `json:"part_of_speech"`          /// 品詞
+	PartOfSpeechDetail1   string `json:"part_of_speech_detail_1"` /// 品詞細分類1
+	PartOfSpeechDetail2   string `json:"part_of_speech_detail_2"` /// 品詞細分類2
+	PartOfSpeechDetail3   string `json:"part_of_speech_detail_3"` /// 品詞細分類3
+	InflectionalType      string `json:"inflectional_type"`       /// 活用型
+	InflectionalForm      string `json:"inflectional_form"`       /// 活用形
+	Stem                  string `json:"stem"`                    /// 原形
+	Yomi                  string `json:"yomi"`                    /// 読み
+	Pronunciation         string `json:"pronunciation"`           /// 発音 (カタカナ)
+	AccentType            int    `json:"accent_type"`             /// アクセント核の位置、0 なら平板
+	MoraCount             *int   `json:"mora_count"`              /// モーラ数
+	AccentAssociativeRule string `json:"accent_associative_rule"` /// アクセント結合規則
+}
+
+// WordType returns the word type the part of speech of w stands for, or ""
+// when it is none of them, e.g. for a word imported from elsewhere.
+func (w UserDictWord) WordType() string {
+	pos := [3]string{w.PartOfSpeech, w.PartOfSpeechDetail1, w.PartOfSpeechDetail2}
+	for t, p := range partOfSpeech {
+		if p == pos {
+			return t
+		}
+	}
+	return ""
+}
+
+// Word is a word to add to the user dictionary.
+type Word struct {
+	Surface       string `json:"surface"`
+	Pronunciation string `json:"pronunciation"` // katakana
+	// AccentType is the mora after which the pitch falls, 0 for a flat
+	// word.
+	AccentType int `json:"accent_type"`
+	// WordType is one of ProperNoun (the default), CommonNoun, Verb,
+	// Adjective or Suffix.
+	WordType string `json:"word_type,omitempty"`
+	// Priority goes from 0 to 10. nil means DefaultPriority.
+	Priority *int `json:"priority,omitempty"`
+}
+
+func (w Word) values() url.Values {
+	v := url.Values{
+		"surface":       {w.Surface},
+		"pronunciation": {w.Pronunciation},
+		"accent_type":   {strconv.Itoa(w.AccentType)},
+	}
+	if w.WordType != "" {
+		v.Set("word_type", w.WordType)
+	}
+	if w.Priority != nil {
+		v.Set("priority", strconv.Itoa(*w.Priority))
+	}
+	return v
+}
+
+func (w Word) wordType() string {
+	if w.WordType == "" {
+		return ProperNoun
+	}
+	return w.WordType
+}
+
+func (w Word) priority() int {
+	if w.Priority == nil {
+		return DefaultPriority
+	}
+	return *w.Priority
+}
+
+// UserDict returns the user dictionary keyed by word UUID.
+func (c *Client) UserDict(ctx context.Context) (map[string]UserDictWord, error) {
+	var dict map[string]UserDictWord
+	if err := c.do(ctx, http.MethodGet, "/user_dict", nil, nil, &dict); err != nil {
+		return nil, err
+	}
+	return dict, nil
+}
+
+// AddWord adds w to the user dictionary and returns its UUID. It is not
+// retried, since a repeated request would add the word twice.
+func (c *Client) AddWord(ctx context.Context, w Word) (string, error) {
+	var id string
+	if err := c.doOnce(ctx, http.MethodPost, "/user_dict_word", w.values(), nil, &id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// UpdateWord replaces the word with the given UUID by w. Like AddWord it
+// is not retried.
+func (c *Client) UpdateWord(ctx context.Context, id string, w Word) error {
+	return c.doOnce(ctx, http.MethodPut, "/user_dict_word/"+url.PathEscape(id), w.values(), nil, nil)
+}
+
+// DeleteWord removes the word with the given UUID. It is not retried: if
+// the engine removed the word but the reply was lost, the repeated request
+// would fail with 404.
+func (c *Client) DeleteWord(ctx context.Context, id string) error {
+	return c.doOnce(ctx, http.MethodDelete, "/user_dict_word/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// ImportUserDict merges dict, as returned by UserDict, into the user
+// dictionary. With override set, words with the same UUID are replaced.
+// Like AddWord it is not retried.
+func (c *Client) ImportUserDict(ctx context.Context, dict map[string]UserDictWord, override bool) error {
+	v := url.Values{"override": {strconv.FormatBool(override)}}
+	return c.doOnce(ctx, http.MethodPost, "/import_user_dict", v, dict, nil)
+}
+
+// LoadWords reads a dictionary file: a JSON list of Word, e.g.
+//
+//	[{"surface": "ミクさん", "pronunciation": "ミクサン", "accent_type": 1}]
+func LoadWords(path string) ([]Word, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var words []Word
+	if err := json.Unmarshal(data, &words); err != nil {
+		return nil, fmt.Errorf("voicevox: dictionary %s: %w", path, err)
+	}
+	return words, nil
+}
+
+// SyncWords makes the user dictionary read every word of words the way it
+// is written there: missing words are added and words whose reading,
+// accent, word type or priority differ are updated. Other words are left alone. It
+// returns the number of words added and updated.
+func (c *Client) SyncWords(ctx context.Context, words []Word) (added, updated int, err error) {
+	dict, err := c.UserDict(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	bySurface := make(map[string]string, len(dict))
+	for id, w := range dict {
+		bySurface[w.Surface] = id
+	}
+	for _, w := range words {
+		id, ok := bySurface[zenkaku(w.Surface)]
+		if !ok {
+			if _, err := c.AddWord(ctx, w); err != nil {
+				return added, updated, fmt.Errorf("%s: %w", w.Surface, err)
+			}
+			added++
+			continue
+		}
+		old := dict[id]
+		if old.Pronunciation == w.Pronunciation && old.AccentType == w.AccentType &&
+			old.WordType() == w.wordType() && old.Priority == w.priority() {
+			continue
+		}
+		if err := c.UpdateWord(ctx, id, w); err != nil {
+			return added, updated, fmt.Errorf("%s: %w", w.Surface, err)
+		}
+		updated++
+	}
+	return added, updated, nil
+}
+
+// zenkaku converts ASCII to full width, as the engine does with the
+// surface of every word it stores.
+func zenkaku(s string) string {
+	r := []rune(s)
+	for i, c := range r {
+		switch {
+		case c == ' ':
+			r[i] = '　'
+		case c > ' ' && c <= '~':
+			r[i] = c + 0xfee0
+		}
+	}
+	return string(r)
+}
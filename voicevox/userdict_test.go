@@ -0,0 +1,88 @@
+package voicevox
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// userDictJSON is a /user_dict answer: ミク as a proper noun with the
+// default priority, and ボカロ as a common noun.
+const userDictJSON = `{
+	"id-miku": {"surface": "ミク", "pronunciation": "ミク", "accent_type": 1, "priority": 5,
+		"part_of_speech": "名詞", "part_of_speech_detail_1": "固有名詞", "part_of_speech_detail_2": "一般", "part_of_speech_detail_3": "*"},
+	"id-vocalo": {"surface": "ボカロ", "pronunciation": "ボカロ", "accent_type": 0, "priority": 5,
+		"part_of_speech": "名詞", "part_of_speech_detail_1": "一般", "part_of_speech_detail_2": "*", "part_of_speech_detail_3": "*"},
+	"id-piapro": {"surface": "ＰＩＡＰＲＯ", "pronunciation": "ピアプロ", "accent_type": 0, "priority": 5,
+		"part_of_speech": "名詞", "part_of_speech_detail_1": "固有名詞", "part_of_speech_detail_2": "一般", "part_of_speech_detail_3": "*"}
+}`
+
+func TestSyncWords(t *testing.T) {
+	var mu sync.Mutex
+	var added, updated []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /user_dict", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(userDictJSON))
+	})
+	mux.HandleFunc("POST /user_dict_word", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		added = append(added, r.URL.Query().Get("surface"))
+		w.Write([]byte(`"id-new"`))
+	})
+	mux.HandleFunc("PUT /user_dict_word/{id}", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		updated = append(updated, r.PathValue("id")+" "+r.URL.Query().Get("word_type"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	c := newEngine(t, mux)
+
+	five := 5
+	words := []Word{
+		{Surface: "ミク", Pronunciation: "ミク", AccentType: 1},                         // そのまま
+		{Surface: "PIAPRO", Pronunciation: "ピアプロ", AccentType: 0, Priority: &five},  // 全角にして照合
+		{Surface: "ボカロ", Pronunciation: "ボカロ", AccentType: 0, WordType: ProperNoun}, // 品詞だけ違う
+		{Surface: "リン", Pronunciation: "リン", AccentType: 1},                         // 辞書にない
+		{Surface: "ミク", Pronunciation: "ミク", AccentType: 1, WordType: CommonNoun},   // 同じ表記の 2 つ目
+	}
+	a, u, err := c.SyncWords(context.Background(), words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != 1 || !slices.Equal(added, []string{"リン"}) {
+		t.Errorf("added %d %v, want リン", a, added)
+	}
+	if want := []string{"id-vocalo " + ProperNoun, "id-miku " + CommonNoun}; u != 2 || !slices.Equal(updated, want) {
+		t.Errorf("updated %d %v, want %v", u, updated, want)
+	}
+}
+
+func TestUserDictWordType(t *testing.T) {
+	for typ, pos := range partOfSpeech {
+		w := UserDictWord{PartOfSpeech: pos[0], PartOfSpeechDetail1: pos[1], PartOfSpeechDetail2: pos[2]}
+		if got := w.WordType(); got != typ {
+			t.Errorf("WordType of %v = %q, want %q", pos, got, typ)
+		}
+	}
+	if got := (UserDictWord{PartOfSpeech: "感動詞"}).WordType(); got != "" {
+		t.Errorf("WordType of an unknown part of speech = %q", got)
+	}
+}
+
+func TestZenkaku(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"PIAPRO", "ＰＩＡＰＲＯ"},
+		{"Miku 39!", "Ｍｉｋｕ　３９！"},
+		{"~", "～"},
+		{"ミクさん", "ミクさん"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := zenkaku(tt.in); got != tt.want {
+			t.Errorf("zenkaku(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
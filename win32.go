@@ -1,3 +1,5 @@
+//go:build windows
+
 package main
 
 import (
@@ -155,9 +157,26 @@ func runMessageLoop(done chan struct{}) {
 	close(done)
 }
 
+// pumpWindowMessages dispatches the window messages waiting on the calling
+// thread without blocking. It returns false once WM_QUIT arrives.
+func pumpWindowMessages() bool {
+	var msg MSG
+	for {
+		ret, _, _ := procPeekMessageW.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0, PM_REMOVE)
+		if ret == 0 {
+			return true
+		}
+		if msg.Message == 0x0012 { // WM_QUIT
+			return false
+		}
+		procTranslateMessage.Call(uintptr(unsafe.Pointer(&msg)))
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&msg)))
+	}
+}
+
 // OpenPluginGUIWithWindow creates a Win32 window, opens the plugin editor with that window as parent,
 // runs a message loop in a goroutine, waits for Enter on stdin, then closes the editor.
-func OpenPluginGUIWithWindow(plugin *vst2.Plugin, opcodes map[string]int) error {
+func OpenPluginGUIWithWindow(plugin Plugin, opcodes map[string]int) error {
 	openCode, ok := opcodes["PlugEditOpen"]
 	if !ok {
 		return fmt.Errorf("PlugEditOpen opcode not found")